@@ -5,16 +5,25 @@ import (
 	"encoding/hex"
 	"os"
 	"sync"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 // Config holds the application configuration
 type Config struct {
-	Server  ServerConfig  `yaml:"server"`
-	Proxy   ProxyConfig   `yaml:"proxy"`
-	Storage StorageConfig `yaml:"storage"`
-	Routes  []RouteConfig `yaml:"routes"`
+	Server     ServerConfig      `yaml:"server"`
+	Proxy      ProxyConfig       `yaml:"proxy"`
+	Storage    StorageConfig     `yaml:"storage"`
+	CORS       CORSConfig        `yaml:"cors" json:"cors"`
+	RateLimit  RateLimitConfig   `yaml:"rate_limit" json:"rate_limit"`
+	Jobs       JobsConfig        `yaml:"jobs" json:"jobs"`
+	Alerts     AlertsConfig      `yaml:"alerts" json:"alerts"`
+	Routes     []RouteConfig     `yaml:"routes"`
+	ClientKeys []ClientKeyConfig `yaml:"client_keys" json:"client_keys"`
+	// TokenBudgets take an account out of rotation for a model once it has
+	// used its local token budget; the first matching rule applies
+	TokenBudgets []TokenBudgetRule `yaml:"token_budgets" json:"token_budgets"`
 }
 
 type ServerConfig struct {
@@ -27,6 +36,17 @@ type ServerConfig struct {
 	AutoStart          bool   `yaml:"autostart" json:"autostart"`
 	GoogleClientID     string `yaml:"google_client_id" json:"google_client_id"`
 	GoogleClientSecret string `yaml:"google_client_secret" json:"google_client_secret"`
+
+	// Timezone is the IANA name days are counted in for stats and daily
+	// budgets; empty means the server's local time
+	Timezone string `yaml:"timezone" json:"timezone"`
+
+	// TLS: when both cert and key are set the server speaks HTTPS only.
+	// Files are watched and reloaded on change.
+	TLSCert       string `yaml:"tls_cert" json:"tls_cert"`
+	TLSKey        string `yaml:"tls_key" json:"tls_key"`
+	TLSClientCA   string `yaml:"tls_client_ca" json:"tls_client_ca"`
+	TLSClientAuth string `yaml:"tls_client_auth" json:"tls_client_auth"` // none, request, require
 }
 
 type ProxyConfig struct {
@@ -36,6 +56,11 @@ type ProxyConfig struct {
 	StreamEnabled bool   `yaml:"stream_enabled" json:"stream_enabled"`
 	ScheduleMode  string `yaml:"schedule_mode" json:"schedule_mode"`
 	MaxWaitTime   int    `yaml:"max_wait_time" json:"max_wait_time"`
+
+	// MaxConcurrency caps in-flight requests per upstream account; 0 or
+	// less means unlimited. MaxConcurrencyByType overrides it per account type.
+	MaxConcurrency       int            `yaml:"max_concurrency" json:"max_concurrency"`
+	MaxConcurrencyByType map[string]int `yaml:"max_concurrency_by_type" json:"max_concurrency_by_type"`
 }
 
 type StorageConfig struct {
@@ -43,9 +68,118 @@ type StorageConfig struct {
 	EncryptKey string `yaml:"encrypt_key"`
 }
 
+// CORSConfig holds separate CORS policies for the proxy and management API
+type CORSConfig struct {
+	Proxy CORSPolicy `yaml:"proxy" json:"proxy"`
+	API   CORSPolicy `yaml:"api" json:"api"`
+}
+
+// CORSPolicy describes which cross-origin requests are allowed.
+// An empty AllowedOrigins list means same-origin only; "*" allows any origin.
+type CORSPolicy struct {
+	AllowedOrigins   []string `yaml:"allowed_origins" json:"allowed_origins"`
+	AllowedMethods   []string `yaml:"allowed_methods" json:"allowed_methods"`
+	AllowedHeaders   []string `yaml:"allowed_headers" json:"allowed_headers"`
+	AllowCredentials bool     `yaml:"allow_credentials" json:"allow_credentials"`
+	MaxAge           int      `yaml:"max_age" json:"max_age"`
+}
+
+// RateLimitConfig holds ingress limits for the proxy endpoints.
+// Each scope is enforced independently; a zero value means unlimited.
+// PerKey applies to the keys listed in ClientKeys only.
+type RateLimitConfig struct {
+	Enabled bool          `yaml:"enabled" json:"enabled"`
+	PerKey  RateLimitRule `yaml:"per_key" json:"per_key"`
+	PerIP   RateLimitRule `yaml:"per_ip" json:"per_ip"`
+	Global  RateLimitRule `yaml:"global" json:"global"`
+}
+
+// RateLimitRule describes token-bucket and concurrency limits for one scope
+type RateLimitRule struct {
+	RequestsPerMinute int `yaml:"requests_per_minute" json:"requests_per_minute"`
+	TokensPerMinute   int `yaml:"tokens_per_minute" json:"tokens_per_minute"`
+	MaxInFlight       int `yaml:"max_in_flight" json:"max_in_flight"`
+}
+
+// JobsConfig controls the background maintenance jobs. Intervals are in
+// minutes; 0 disables the schedule but the job can still be run manually.
+type JobsConfig struct {
+	Enabled                bool `yaml:"enabled" json:"enabled"`
+	Workers                int  `yaml:"workers" json:"workers"` // accounts processed in parallel
+	HealthCheckInterval    int  `yaml:"health_check_interval" json:"health_check_interval"`
+	QuotaRefreshInterval   int  `yaml:"quota_refresh_interval" json:"quota_refresh_interval"`
+	ExpiredRecheckInterval int  `yaml:"expired_recheck_interval" json:"expired_recheck_interval"`
+}
+
+// AlertsConfig sends account and pool health alerts to webhooks. An alert
+// that keeps firing is repeated at most once per Cooldown minutes.
+type AlertsConfig struct {
+	Enabled  bool            `yaml:"enabled" json:"enabled"`
+	Cooldown int             `yaml:"cooldown" json:"cooldown"`
+	Rules    AlertRules      `yaml:"rules" json:"rules"`
+	Webhooks []WebhookConfig `yaml:"webhooks" json:"webhooks"`
+}
+
+// AlertRules selects which conditions raise alerts; zero values disable a rule
+type AlertRules struct {
+	StatusChange []string `yaml:"status_change" json:"status_change"` // statuses that alert, e.g. expired, banned
+	NoAccounts   bool     `yaml:"no_accounts" json:"no_accounts"`
+	QuotaBelow   int      `yaml:"quota_below" json:"quota_below"`   // percent of a model's pool quota left
+	ErrorRate    int      `yaml:"error_rate" json:"error_rate"`     // percent of failed requests
+	ErrorWindow  int      `yaml:"error_window" json:"error_window"` // minutes the error rate is measured over
+	MinRequests  int      `yaml:"min_requests" json:"min_requests"` // requests needed in the window
+}
+
+// WebhookConfig is an alert target. Format is json, slack or discord.
+type WebhookConfig struct {
+	Name   string   `yaml:"name" json:"name"`
+	URL    string   `yaml:"url" json:"url"`
+	Format string   `yaml:"format" json:"format"`
+	Rules  []string `yaml:"rules" json:"rules"` // rules sent to this target; empty means all
+}
+
+// TokenBudgetRule caps the tokens each matching account may use on matching
+// models. Empty matchers match anything; zero limits are not enforced.
+type TokenBudgetRule struct {
+	Model       string `yaml:"model" json:"model"`               // glob pattern, e.g. "claude-opus-*"
+	AccountType string `yaml:"account_type" json:"account_type"` // free, pro or ultra
+	Email       string `yaml:"email" json:"email"`
+	Daily       int64  `yaml:"daily" json:"daily"`           // tokens per calendar day
+	Rolling5h   int64  `yaml:"rolling_5h" json:"rolling_5h"` // tokens per rolling 5 hours
+}
+
 type RouteConfig struct {
 	Pattern string `yaml:"pattern"`
 	Target  string `yaml:"target"`
+	// Pools restricts requests matching this route to accounts tagged
+	// with one of these pools; empty means any account
+	Pools []string `yaml:"pools,omitempty" json:"pools,omitempty"`
+}
+
+// ClientKeyConfig restricts a client API key to account pools
+type ClientKeyConfig struct {
+	Name  string   `yaml:"name" json:"name"`
+	Key   string   `yaml:"key" json:"key"`
+	Pools []string `yaml:"pools" json:"pools"`
+}
+
+// locations caches loaded timezones by name
+var locations sync.Map
+
+// Location returns the configured timezone, falling back to local time
+func (c *Config) Location() *time.Location {
+	if c == nil || c.Server.Timezone == "" {
+		return time.Local
+	}
+	if loc, ok := locations.Load(c.Server.Timezone); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(c.Server.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	locations.Store(c.Server.Timezone, loc)
+	return loc
 }
 
 var (
@@ -77,6 +211,45 @@ func DefaultConfig() *Config {
 			DBPath:     "./data/antigravity.db",
 			EncryptKey: "",
 		},
+		CORS: CORSConfig{
+			Proxy: CORSPolicy{
+				AllowedOrigins: []string{"*"},
+				AllowedMethods: []string{"GET", "POST", "OPTIONS"},
+				AllowedHeaders: []string{"Content-Type", "Authorization", "X-API-Key", "anthropic-version", "x-goog-api-key"},
+				MaxAge:         600,
+			},
+			API: CORSPolicy{
+				AllowedOrigins: []string{},
+				AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+				AllowedHeaders: []string{"Content-Type", "Authorization", "X-API-Key"},
+				MaxAge:         600,
+			},
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: false,
+			PerKey:  RateLimitRule{RequestsPerMinute: 120, TokensPerMinute: 2000000, MaxInFlight: 8},
+			PerIP:   RateLimitRule{RequestsPerMinute: 240, MaxInFlight: 16},
+			Global:  RateLimitRule{MaxInFlight: 64},
+		},
+		Jobs: JobsConfig{
+			Enabled:                false,
+			Workers:                4,
+			HealthCheckInterval:    30,
+			QuotaRefreshInterval:   15,
+			ExpiredRecheckInterval: 360,
+		},
+		Alerts: AlertsConfig{
+			Enabled:  true,
+			Cooldown: 30,
+			Rules: AlertRules{
+				StatusChange: []string{"expired", "banned"},
+				NoAccounts:   true,
+				QuotaBelow:   10,
+				ErrorRate:    50,
+				ErrorWindow:  10,
+				MinRequests:  20,
+			},
+		},
 		Routes: []RouteConfig{
 			{Pattern: "gpt-4*", Target: "gemini-3-pro-high"},
 			{Pattern: "gpt-4o*", Target: "gemini-3-flash"},
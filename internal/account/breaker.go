@@ -0,0 +1,163 @@
+package account
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of an account's circuit breaker
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"    // requests flow normally
+	BreakerOpen     BreakerState = "open"      // account is skipped until the backoff ends
+	BreakerHalfOpen BreakerState = "half_open" // a single probe request is allowed through
+)
+
+// Circuit breaker tuning
+const (
+	breakerThreshold    = 3                // consecutive failures before the breaker opens
+	breakerBaseBackoff  = 10 * time.Second // first open period
+	breakerMaxBackoff   = 10 * time.Minute
+	breakerJitter       = 0.2             // +/- fraction applied to each backoff
+	breakerProbeTimeout = 2 * time.Minute // a probe that never reports back is abandoned
+)
+
+// CircuitStatus is a snapshot of an account's circuit breaker
+type CircuitStatus struct {
+	State     BreakerState `json:"state"`
+	Failures  int          `json:"failures"`
+	OpenUntil time.Time    `json:"open_until"`
+	LastError string       `json:"last_error,omitempty"`
+}
+
+type circuit struct {
+	state     BreakerState
+	failures  int // consecutive failures
+	openUntil time.Time
+	probeAt   time.Time // when the half-open probe was let through
+	lastError string
+}
+
+// BreakerSet keeps a circuit breaker per account. Server errors and network
+// failures count against the breaker; rate limits are handled by cooldowns.
+type BreakerSet struct {
+	mu       sync.Mutex
+	circuits map[int64]*circuit
+}
+
+// NewBreakerSet creates an empty breaker set
+func NewBreakerSet() *BreakerSet {
+	return &BreakerSet{
+		circuits: make(map[int64]*circuit),
+	}
+}
+
+// Wait returns how long the account must be skipped: the rest of the open
+// period, or the probe timeout while a half-open probe is in flight
+func (b *BreakerSet) Wait(accountID int64, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[accountID]
+	if !ok {
+		return 0
+	}
+
+	switch c.state {
+	case BreakerOpen:
+		if now.Before(c.openUntil) {
+			return c.openUntil.Sub(now)
+		}
+	case BreakerHalfOpen:
+		if deadline := c.probeAt.Add(breakerProbeTimeout); now.Before(deadline) {
+			return deadline.Sub(now)
+		}
+	}
+	return 0
+}
+
+// Acquire is called when an account is selected. An open breaker whose
+// backoff has ended moves to half-open and this request becomes the probe.
+func (b *BreakerSet) Acquire(accountID int64, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[accountID]
+	if !ok || c.state == BreakerClosed {
+		return
+	}
+	c.state = BreakerHalfOpen
+	c.probeAt = now
+}
+
+// Success closes the breaker
+func (b *BreakerSet) Success(accountID int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.circuits, accountID)
+}
+
+// Failure records a failed request. The breaker opens after breakerThreshold
+// consecutive failures, and a failed probe reopens it with a longer backoff.
+func (b *BreakerSet) Failure(accountID int64, reason string, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[accountID]
+	if !ok {
+		c = &circuit{state: BreakerClosed}
+		b.circuits[accountID] = c
+	}
+
+	c.failures++
+	c.lastError = reason
+
+	if c.state == BreakerHalfOpen || c.failures >= breakerThreshold {
+		c.state = BreakerOpen
+		c.openUntil = now.Add(breakerBackoff(c.failures))
+	}
+}
+
+// Status returns the breaker state of an account
+func (b *BreakerSet) Status(accountID int64) CircuitStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[accountID]
+	if !ok {
+		return CircuitStatus{State: BreakerClosed}
+	}
+	status := CircuitStatus{
+		State:     c.state,
+		Failures:  c.failures,
+		LastError: c.lastError,
+	}
+	if c.state == BreakerOpen {
+		status.OpenUntil = c.openUntil
+	}
+	return status
+}
+
+// Reset closes the breaker of an account, e.g. after a manual status check
+func (b *BreakerSet) Reset(accountID int64) {
+	b.Success(accountID)
+}
+
+// breakerBackoff doubles the open period for each failure past the
+// threshold, capped at breakerMaxBackoff, with jitter so accounts that
+// failed together are not probed together
+func breakerBackoff(failures int) time.Duration {
+	exp := failures - breakerThreshold
+	if exp < 0 {
+		exp = 0
+	}
+	backoff := float64(breakerBaseBackoff) * math.Pow(2, float64(exp))
+	if backoff > float64(breakerMaxBackoff) {
+		backoff = float64(breakerMaxBackoff)
+	}
+	backoff *= 1 + breakerJitter*(2*rand.Float64()-1)
+	return time.Duration(backoff)
+}
@@ -0,0 +1,88 @@
+package account
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreakerStateMachine(t *testing.T) {
+	const id = 1
+	b := NewBreakerSet()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		name      string
+		action    func()
+		wantState BreakerState
+		wantWait  bool // whether the account is skipped at now
+	}{
+		{"starts closed", func() {}, BreakerClosed, false},
+		{"one failure stays closed", func() { b.Failure(id, "HTTP 500", now) }, BreakerClosed, false},
+		{"two failures stay closed", func() { b.Failure(id, "HTTP 500", now) }, BreakerClosed, false},
+		{"threshold opens", func() { b.Failure(id, "HTTP 502", now) }, BreakerOpen, true},
+		{"backoff ends", func() { now = now.Add(breakerBaseBackoff * 2) }, BreakerOpen, false},
+		{"selection makes it the probe", func() { b.Acquire(id, now) }, BreakerHalfOpen, true},
+		{"failed probe reopens", func() { b.Failure(id, "HTTP 503", now) }, BreakerOpen, true},
+		{"second backoff ends", func() { now = now.Add(breakerBaseBackoff * 4) }, BreakerOpen, false},
+		{"second probe", func() { b.Acquire(id, now) }, BreakerHalfOpen, true},
+		{"abandoned probe times out", func() { now = now.Add(breakerProbeTimeout) }, BreakerHalfOpen, false},
+		{"successful probe closes", func() { b.Success(id) }, BreakerClosed, false},
+		{"failures count from zero again", func() { b.Failure(id, "HTTP 500", now) }, BreakerClosed, false},
+	}
+
+	for _, step := range steps {
+		step.action()
+		status := b.Status(id)
+		if status.State != step.wantState {
+			t.Fatalf("%s: state = %s, want %s", step.name, status.State, step.wantState)
+		}
+		if waiting := b.Wait(id, now) > 0; waiting != step.wantWait {
+			t.Errorf("%s: waiting = %v, want %v", step.name, waiting, step.wantWait)
+		}
+	}
+	if got := b.Status(id); got.Failures != 1 || got.LastError != "HTTP 500" {
+		t.Errorf("status = %+v, want 1 failure with the last error", got)
+	}
+}
+
+func TestBreakerBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		base     time.Duration
+	}{
+		{1, breakerBaseBackoff},
+		{breakerThreshold, breakerBaseBackoff},
+		{breakerThreshold + 1, 2 * breakerBaseBackoff},
+		{breakerThreshold + 3, 8 * breakerBaseBackoff},
+		{breakerThreshold + 20, breakerMaxBackoff},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			got := breakerBackoff(tt.failures)
+			lo := time.Duration(float64(tt.base) * (1 - breakerJitter))
+			hi := time.Duration(float64(tt.base) * (1 + breakerJitter))
+			if got < lo || got > hi {
+				t.Fatalf("breakerBackoff(%d) = %s, want within %s..%s", tt.failures, got, lo, hi)
+			}
+		}
+	}
+}
+
+func TestClientErrorReleasesProbe(t *testing.T) {
+	const id = 1
+	m := &Manager{breakers: NewBreakerSet()}
+	now := time.Now()
+	for i := 0; i < breakerThreshold; i++ {
+		m.breakers.Failure(id, "HTTP 500", now.Add(-time.Hour))
+	}
+	m.breakers.Acquire(id, now)
+
+	m.MarkAccountError(id, "gemini-2.5-pro", 400, nil)
+
+	if got := m.breakers.Status(id).State; got != BreakerClosed {
+		t.Errorf("state after a 400 on the probe = %s, want %s", got, BreakerClosed)
+	}
+	if wait := m.breakers.Wait(id, now); wait != 0 {
+		t.Errorf("account still skipped for %s", wait)
+	}
+}
@@ -0,0 +1,127 @@
+package account
+
+import (
+	"log"
+	"sort"
+	"time"
+)
+
+// accountCache is the in-memory snapshot selection works from. It is
+// reloaded when the storage version changes, i.e. after any write to
+// accounts or model quotas, so the request path does not query SQLite.
+type accountCache struct {
+	version int64
+	loaded  bool
+	active  []Account                       // active accounts
+	quotas  map[string]map[int64]ModelQuota // per-model quota, loaded lazily
+}
+
+// activeAccounts returns a copy of the active accounts, ordered by tier,
+// remaining quota and least recent use. Must be called with m.mu held.
+func (m *Manager) activeAccounts() ([]Account, error) {
+	if err := m.syncCache(); err != nil {
+		return nil, err
+	}
+	accounts := make([]Account, len(m.cache.active))
+	copy(accounts, m.cache.active)
+	sortActive(accounts)
+	return accounts, nil
+}
+
+// modelQuotas returns the cached per-model quota by account ID. Must be
+// called with m.mu held.
+func (m *Manager) modelQuotas(model string) map[int64]ModelQuota {
+	if err := m.syncCache(); err != nil {
+		return nil
+	}
+	if quotas, ok := m.cache.quotas[model]; ok {
+		return quotas
+	}
+	quotas, err := m.storage.GetModelQuotas(model)
+	if err != nil {
+		return nil
+	}
+	m.cache.quotas[model] = quotas
+	return quotas
+}
+
+// syncCache reloads the snapshot if storage changed since it was taken.
+// Last used times that are not flushed yet are kept.
+func (m *Manager) syncCache() error {
+	version := m.storage.Version()
+	if m.cache.loaded && m.cache.version == version {
+		return nil
+	}
+
+	accounts, err := m.storage.GetActiveAccounts()
+	if err != nil {
+		return err
+	}
+	for i := range accounts {
+		if t, ok := m.lastUsed[accounts[i].ID]; ok {
+			accounts[i].LastUsedAt = t
+		}
+	}
+
+	m.cache = accountCache{
+		version: version,
+		loaded:  true,
+		active:  accounts,
+		quotas:  make(map[string]map[int64]ModelQuota),
+	}
+	return nil
+}
+
+// touch records that an account was just selected. The time is written
+// to storage by the next flush. Must be called with m.mu held.
+func (m *Manager) touch(id int64, now time.Time) {
+	m.lastUsed[id] = now
+	for i := range m.cache.active {
+		if m.cache.active[i].ID == id {
+			m.cache.active[i].LastUsedAt = now
+			break
+		}
+	}
+}
+
+// flushLastUsed writes pending last used times. Entries stay pending until
+// written so a cache reload in between does not lose them.
+func (m *Manager) flushLastUsed() {
+	m.mu.RLock()
+	pending := make(map[int64]time.Time, len(m.lastUsed))
+	for id, t := range m.lastUsed {
+		pending[id] = t
+	}
+	m.mu.RUnlock()
+
+	if len(pending) == 0 {
+		return
+	}
+	if err := m.storage.SaveLastUsed(pending); err != nil {
+		log.Printf("Failed to persist last used times: %v", err)
+		return
+	}
+
+	m.mu.Lock()
+	for id, t := range pending {
+		if m.lastUsed[id].Equal(t) {
+			delete(m.lastUsed, id)
+		}
+	}
+	m.mu.Unlock()
+}
+
+// sortActive orders accounts like Storage.GetActiveAccounts: tier, then
+// remaining quota, then least recently used (never used first)
+func sortActive(accounts []Account) {
+	sort.SliceStable(accounts, func(i, j int) bool {
+		a, b := accounts[i], accounts[j]
+		if ta, tb := tierRank(a.AccountType), tierRank(b.AccountType); ta != tb {
+			return ta < tb
+		}
+		if ra, rb := a.QuotaLimit-a.QuotaUsed, b.QuotaLimit-b.QuotaUsed; ra != rb {
+			return ra > rb
+		}
+		return a.LastUsedAt.Before(b.LastUsedAt)
+	})
+}
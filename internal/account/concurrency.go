@@ -0,0 +1,68 @@
+package account
+
+import "sync"
+
+// ConcurrencyTracker counts in-flight requests per account and lets
+// waiters know when a slot is released
+type ConcurrencyTracker struct {
+	mu       sync.Mutex
+	inFlight map[int64]int
+	released chan struct{} // closed and replaced on every release
+}
+
+// NewConcurrencyTracker creates an empty tracker
+func NewConcurrencyTracker() *ConcurrencyTracker {
+	return &ConcurrencyTracker{
+		inFlight: make(map[int64]int),
+		released: make(chan struct{}),
+	}
+}
+
+// TryAcquire takes a slot on an account unless it already has limit
+// requests in flight. A limit <= 0 means unlimited.
+func (t *ConcurrencyTracker) TryAcquire(accountID int64, limit int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if limit > 0 && t.inFlight[accountID] >= limit {
+		return false
+	}
+	t.inFlight[accountID]++
+	return true
+}
+
+// Release frees a slot taken by TryAcquire and wakes waiters
+func (t *ConcurrencyTracker) Release(accountID int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.inFlight[accountID] <= 1 {
+		delete(t.inFlight, accountID)
+	} else {
+		t.inFlight[accountID]--
+	}
+	close(t.released)
+	t.released = make(chan struct{})
+}
+
+// Saturated reports whether an account has reached its limit
+func (t *ConcurrencyTracker) Saturated(accountID int64, limit int) bool {
+	if limit <= 0 {
+		return false
+	}
+	return t.Count(accountID) >= limit
+}
+
+// Count returns the number of in-flight requests on an account
+func (t *ConcurrencyTracker) Count(accountID int64) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.inFlight[accountID]
+}
+
+// Released returns a channel that is closed on the next release
+func (t *ConcurrencyTracker) Released() <-chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.released
+}
@@ -0,0 +1,196 @@
+package account
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"antigravity-lite/internal/redact"
+)
+
+// LimitKind distinguishes short throttling from exhausted quota
+type LimitKind string
+
+const (
+	LimitThrottled LimitKind = "throttled"       // per-minute rate limit, retry soon
+	LimitExhausted LimitKind = "quota_exhausted" // daily quota used up, wait for reset
+	LimitServer    LimitKind = "server_error"    // upstream 5xx, brief backoff
+)
+
+// Default cooldowns when the upstream gives no hint
+const (
+	defaultThrottleCooldown = 60 * time.Second
+	defaultServerCooldown   = 10 * time.Second
+)
+
+// UpstreamError is a non-200 response from the upstream API.
+// It keeps the headers and body so cooldowns can be derived from them.
+type UpstreamError struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// maxErrorBody caps how much of the upstream body Error includes
+const maxErrorBody = 300
+
+// Error returns a redacted, shortened message that is safe to store as the
+// account's last error and show in the UI
+func (e *UpstreamError) Error() string {
+	msg := redact.String(strings.TrimSpace(string(e.Body)))
+	if len(msg) > maxErrorBody {
+		msg = strings.ToValidUTF8(msg[:maxErrorBody], "") + "..."
+	}
+	return "API error: " + msg
+}
+
+// Detail returns the full, unredacted upstream body for the request log
+func (e *UpstreamError) Detail() string {
+	return fmt.Sprintf("API error: %s", string(e.Body))
+}
+
+// NewUpstreamError reads what is needed from an upstream response
+func NewUpstreamError(resp *http.Response, body []byte) *UpstreamError {
+	return &UpstreamError{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Body:       body,
+	}
+}
+
+// Cooldown says how long an account should be set aside after an error
+type Cooldown struct {
+	Kind    LimitKind
+	ResetAt time.Time
+	Reason  string
+}
+
+// googleError is the google.rpc.Status error envelope
+type googleError struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+		Details []struct {
+			Type       string `json:"@type"`
+			RetryDelay string `json:"retryDelay"`
+			Reason     string `json:"reason"`
+			Metadata   struct {
+				QuotaResetDelay     string `json:"quotaResetDelay"`
+				QuotaResetTimeStamp string `json:"quotaResetTimeStamp"`
+			} `json:"metadata"`
+			Violations []struct {
+				QuotaID     string `json:"quotaId"`
+				QuotaMetric string `json:"quotaMetric"`
+				Description string `json:"description"`
+			} `json:"violations"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+// ParseCooldown derives the cooldown for a 429 or 5xx response from the
+// Retry-After header and google.rpc RetryInfo / QuotaFailure / ErrorInfo details
+func ParseCooldown(statusCode int, header http.Header, body []byte, now time.Time) Cooldown {
+	cd := Cooldown{Kind: LimitThrottled}
+	if statusCode >= 500 {
+		cd.Kind = LimitServer
+	}
+
+	// retryDelay is the generic "try again in" hint; quotaReset is when an
+	// exhausted quota comes back, which is what matters for daily limits
+	var retryDelay time.Duration
+	var resetAt, quotaReset time.Time
+
+	// Retry-After: seconds or HTTP date
+	if ra := header.Get("Retry-After"); ra != "" {
+		if secs, err := strconv.Atoi(strings.TrimSpace(ra)); err == nil {
+			retryDelay = time.Duration(secs) * time.Second
+		} else if t, err := http.ParseTime(ra); err == nil {
+			resetAt = t
+		}
+	}
+
+	var gerr googleError
+	if len(body) > 0 && json.Unmarshal(body, &gerr) == nil {
+		msg := strings.ToLower(gerr.Error.Message)
+		cd.Reason = gerr.Error.Status
+
+		for _, d := range gerr.Error.Details {
+			switch {
+			case strings.HasSuffix(d.Type, "google.rpc.RetryInfo"):
+				if dur, err := time.ParseDuration(d.RetryDelay); err == nil && dur > retryDelay {
+					retryDelay = dur
+				}
+			case strings.HasSuffix(d.Type, "google.rpc.ErrorInfo"):
+				if d.Reason != "" {
+					cd.Reason = d.Reason
+				}
+				if d.Reason == "QUOTA_EXHAUSTED" {
+					cd.Kind = LimitExhausted
+				}
+				if dur, err := time.ParseDuration(d.Metadata.QuotaResetDelay); err == nil {
+					quotaReset = now.Add(dur)
+				}
+				if t, err := time.Parse(time.RFC3339, d.Metadata.QuotaResetTimeStamp); err == nil {
+					quotaReset = t
+				}
+			case strings.HasSuffix(d.Type, "google.rpc.QuotaFailure"):
+				for _, v := range d.Violations {
+					id := strings.ToLower(v.QuotaID + " " + v.QuotaMetric)
+					if strings.Contains(id, "perday") || strings.Contains(id, "per_day") || strings.Contains(id, "daily") {
+						cd.Kind = LimitExhausted
+						cd.Reason = v.QuotaID
+					} else if cd.Reason == "" || cd.Reason == gerr.Error.Status {
+						cd.Reason = v.QuotaID
+					}
+				}
+			}
+		}
+
+		if strings.Contains(msg, "exhausted your capacity") || strings.Contains(msg, "quota will reset") ||
+			strings.Contains(msg, "per day") {
+			cd.Kind = LimitExhausted
+		}
+		// "Your quota will reset after 2h30m5s."
+		if m := resetAfterPattern.FindStringSubmatch(msg); m != nil {
+			if dur, err := time.ParseDuration(m[1]); err == nil {
+				quotaReset = now.Add(dur)
+			}
+		}
+	}
+
+	switch {
+	case cd.Kind == LimitExhausted && quotaReset.After(now):
+		cd.ResetAt = quotaReset
+	case cd.Kind == LimitExhausted:
+		// RetryInfo on a daily quota is only a polling hint;
+		// daily quotas reset at midnight Pacific time
+		cd.ResetAt = nextPacificMidnight(now)
+	case !resetAt.IsZero() && resetAt.After(now):
+		cd.ResetAt = resetAt
+	case retryDelay > 0:
+		cd.ResetAt = now.Add(retryDelay)
+	case cd.Kind == LimitServer:
+		cd.ResetAt = now.Add(defaultServerCooldown)
+	default:
+		cd.ResetAt = now.Add(defaultThrottleCooldown)
+	}
+
+	return cd
+}
+
+var resetAfterPattern = regexp.MustCompile(`reset after ((?:\d+h)?(?:\d+m)?(?:[\d.]+s)?)`)
+
+// nextPacificMidnight returns the next midnight in America/Los_Angeles
+func nextPacificMidnight(now time.Time) time.Time {
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		loc = time.FixedZone("PST", -8*3600)
+	}
+	t := now.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+}
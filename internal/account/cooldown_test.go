@@ -0,0 +1,171 @@
+package account
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParseCooldown(t *testing.T) {
+	// 12:00 in Los Angeles (PST), so the daily reset is 08:00 UTC next day
+	now := time.Date(2026, 1, 15, 20, 0, 0, 0, time.UTC)
+	pacificMidnight := time.Date(2026, 1, 16, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		body       string
+		wantKind   LimitKind
+		wantReset  time.Time
+		wantReason string
+	}{
+		{
+			name:      "throttled without hints",
+			status:    429,
+			wantKind:  LimitThrottled,
+			wantReset: now.Add(defaultThrottleCooldown),
+		},
+		{
+			name:      "server error without hints",
+			status:    503,
+			wantKind:  LimitServer,
+			wantReset: now.Add(defaultServerCooldown),
+		},
+		{
+			name:       "Retry-After seconds",
+			status:     429,
+			retryAfter: "30",
+			wantKind:   LimitThrottled,
+			wantReset:  now.Add(30 * time.Second),
+		},
+		{
+			name:       "Retry-After HTTP date",
+			status:     503,
+			retryAfter: "Thu, 15 Jan 2026 20:05:00 GMT",
+			wantKind:   LimitServer,
+			wantReset:  now.Add(5 * time.Minute),
+		},
+		{
+			name:       "Retry-After date in the past falls back to the default",
+			status:     429,
+			retryAfter: "Thu, 15 Jan 2026 19:00:00 GMT",
+			wantKind:   LimitThrottled,
+			wantReset:  now.Add(defaultThrottleCooldown),
+		},
+		{
+			name:   "RetryInfo",
+			status: 429,
+			body: `{"error": {"code": 429, "status": "RESOURCE_EXHAUSTED", "details": [
+				{"@type": "type.googleapis.com/google.rpc.RetryInfo", "retryDelay": "12.5s"}]}}`,
+			wantKind:   LimitThrottled,
+			wantReset:  now.Add(12500 * time.Millisecond),
+			wantReason: "RESOURCE_EXHAUSTED",
+		},
+		{
+			name:       "longer of Retry-After and RetryInfo",
+			status:     429,
+			retryAfter: "5",
+			body: `{"error": {"details": [
+				{"@type": "type.googleapis.com/google.rpc.RetryInfo", "retryDelay": "20s"}]}}`,
+			wantKind:  LimitThrottled,
+			wantReset: now.Add(20 * time.Second),
+		},
+		{
+			name:   "ErrorInfo quota reset delay",
+			status: 429,
+			body: `{"error": {"status": "RESOURCE_EXHAUSTED", "details": [
+				{"@type": "type.googleapis.com/google.rpc.ErrorInfo", "reason": "QUOTA_EXHAUSTED",
+				 "metadata": {"quotaResetDelay": "2h30m"}},
+				{"@type": "type.googleapis.com/google.rpc.RetryInfo", "retryDelay": "1s"}]}}`,
+			wantKind:   LimitExhausted,
+			wantReset:  now.Add(150 * time.Minute),
+			wantReason: "QUOTA_EXHAUSTED",
+		},
+		{
+			name:   "ErrorInfo quota reset timestamp",
+			status: 429,
+			body: `{"error": {"details": [
+				{"@type": "type.googleapis.com/google.rpc.ErrorInfo", "reason": "QUOTA_EXHAUSTED",
+				 "metadata": {"quotaResetTimeStamp": "2026-01-15T23:00:00Z"}}]}}`,
+			wantKind:   LimitExhausted,
+			wantReset:  now.Add(3 * time.Hour),
+			wantReason: "QUOTA_EXHAUSTED",
+		},
+		{
+			name:   "daily QuotaFailure waits for Pacific midnight, not RetryInfo",
+			status: 429,
+			body: `{"error": {"status": "RESOURCE_EXHAUSTED", "details": [
+				{"@type": "type.googleapis.com/google.rpc.QuotaFailure", "violations": [
+					{"quotaId": "GenerateRequestsPerDayPerProjectPerModel"}]},
+				{"@type": "type.googleapis.com/google.rpc.RetryInfo", "retryDelay": "30s"}]}}`,
+			wantKind:   LimitExhausted,
+			wantReset:  pacificMidnight,
+			wantReason: "GenerateRequestsPerDayPerProjectPerModel",
+		},
+		{
+			name:   "per-minute QuotaFailure is throttling",
+			status: 429,
+			body: `{"error": {"status": "RESOURCE_EXHAUSTED", "details": [
+				{"@type": "type.googleapis.com/google.rpc.QuotaFailure", "violations": [
+					{"quotaId": "GenerateRequestsPerMinutePerProjectPerModel"}]}]}}`,
+			wantKind:   LimitThrottled,
+			wantReset:  now.Add(defaultThrottleCooldown),
+			wantReason: "GenerateRequestsPerMinutePerProjectPerModel",
+		},
+		{
+			name:       "reset time in the message",
+			status:     429,
+			body:       `{"error": {"message": "You have exhausted your capacity on this model. Your quota will reset after 1h2m3s."}}`,
+			wantKind:   LimitExhausted,
+			wantReset:  now.Add(time.Hour + 2*time.Minute + 3*time.Second),
+			wantReason: "",
+		},
+		{
+			name:      "body that is not JSON",
+			status:    429,
+			body:      `upstream overloaded`,
+			wantKind:  LimitThrottled,
+			wantReset: now.Add(defaultThrottleCooldown),
+		},
+	}
+
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.retryAfter != "" {
+			header.Set("Retry-After", tt.retryAfter)
+		}
+		cd := ParseCooldown(tt.status, header, []byte(tt.body), now)
+		if cd.Kind != tt.wantKind {
+			t.Errorf("%s: kind = %s, want %s", tt.name, cd.Kind, tt.wantKind)
+		}
+		if !cd.ResetAt.Equal(tt.wantReset) {
+			t.Errorf("%s: reset at %s, want %s", tt.name, cd.ResetAt.UTC(), tt.wantReset)
+		}
+		if cd.Reason != tt.wantReason {
+			t.Errorf("%s: reason = %q, want %q", tt.name, cd.Reason, tt.wantReason)
+		}
+	}
+}
+
+func TestUpstreamErrorMessage(t *testing.T) {
+	body := `{"error": {"code": 403, "message": "Permission denied on project my-proj-123 for user someone@example.com"}}`
+	e := &UpstreamError{StatusCode: 403, Body: []byte(body)}
+
+	msg := e.Error()
+	for _, secret := range []string{"my-proj-123", "someone@example.com"} {
+		if strings.Contains(msg, secret) {
+			t.Errorf("Error() = %q, contains %q", msg, secret)
+		}
+	}
+	if !strings.Contains(e.Detail(), "someone@example.com") {
+		t.Errorf("Detail() = %q, want the raw body", e.Detail())
+	}
+
+	long := &UpstreamError{StatusCode: 500, Body: []byte(strings.Repeat("x", 5000))}
+	if n := len(long.Error()); n > len("API error: ")+maxErrorBody+len("...") {
+		t.Errorf("Error() is %d bytes, want it truncated", n)
+	}
+}
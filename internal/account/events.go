@@ -0,0 +1,55 @@
+package account
+
+import "fmt"
+
+// Event kinds reported to the listener set with OnEvent
+const (
+	EventStatusChange = "status_change"
+	EventNoAccounts   = "no_accounts"
+)
+
+// Event is a change in account or pool health
+type Event struct {
+	Kind     string
+	Account  *Account // status changes only
+	Previous Status
+	Status   Status
+	Reason   string
+	Pools    PoolFilter // no_accounts: the pools requested, empty for any
+}
+
+// NoAccountsError is returned when no active account can serve a request
+type NoAccountsError struct {
+	Pools PoolFilter
+}
+
+func (e *NoAccountsError) Error() string {
+	if len(e.Pools) == 0 {
+		return "no active accounts available"
+	}
+	return fmt.Sprintf("no active accounts in pool %s", e.Pools)
+}
+
+// OnEvent sets the listener for account and pool events. It is called on the
+// request path and must not block or call back into the Manager.
+func (m *Manager) OnEvent(fn func(Event)) {
+	m.onEvent.Store(&fn)
+}
+
+func (m *Manager) emit(e Event) {
+	if fn := m.onEvent.Load(); fn != nil && *fn != nil {
+		(*fn)(e)
+	}
+}
+
+// setStatus stores a status change made by the proxy itself and reports it.
+// Nothing is reported when the account was disabled in the meantime.
+func (m *Manager) setStatus(acc *Account, status Status, reason string) {
+	updated, err := m.storage.UpdateStatus(acc.ID, status)
+	if err != nil || !updated || acc.Status == status {
+		return
+	}
+	previous := acc.Status
+	acc.Status = status
+	m.emit(Event{Kind: EventStatusChange, Account: acc, Previous: previous, Status: status, Reason: reason})
+}
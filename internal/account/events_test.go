@@ -0,0 +1,39 @@
+package account
+
+import "testing"
+
+func TestSetStatusSkipsDisabledAccount(t *testing.T) {
+	m, ids := newTestManager(t, ScheduleBalance, 0)
+	var events []Event
+	m.OnEvent(func(e Event) { events = append(events, e) })
+
+	// A request picked the account, then it was disabled before the request failed
+	acc, err := m.storage.Get(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Disable(ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	m.setStatus(acc, StatusExpired, "HTTP 401")
+
+	if len(events) != 0 {
+		t.Errorf("emitted %+v for a disabled account", events)
+	}
+	if acc.Status != StatusActive {
+		t.Errorf("in-memory status = %s, want it unchanged", acc.Status)
+	}
+	if stored, _ := m.storage.Get(ids[0]); stored.Status != StatusDisabled {
+		t.Errorf("stored status = %s, want %s", stored.Status, StatusDisabled)
+	}
+
+	// Once enabled, status changes are reported again
+	if err := m.storage.Enable(ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	acc.Status = StatusUnknown
+	m.setStatus(acc, StatusExpired, "HTTP 401")
+	if len(events) != 1 || events[0].Previous != StatusUnknown || events[0].Status != StatusExpired {
+		t.Errorf("events = %+v, want one change from unknown to expired", events)
+	}
+}
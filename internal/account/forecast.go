@@ -0,0 +1,143 @@
+package account
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// minForecastSpan is the shortest stretch of snapshots a drain rate is
+// computed from
+const minForecastSpan = 15 * time.Minute
+
+// modelDrain is the quota one model used across accounts within a lookback
+type modelDrain struct {
+	used float64
+	span time.Duration // longest stretch covered by one account's snapshots
+}
+
+// QuotaForecast projects, per model, when the active accounts in the pools
+// run out. The drain rate comes from the quota snapshots and the request rate
+// from the request log, both over the lookback; requests are matched to quota
+// by routed model name. Demand is projected until midnight in the configured
+// timezone.
+func (m *Manager) QuotaForecast(pools PoolFilter, lookback time.Duration) ([]ModelQuotaForecast, error) {
+	accounts, err := m.storage.GetActiveAccounts()
+	if err != nil {
+		return nil, err
+	}
+	active := make(map[int64]bool)
+	for _, acc := range pools.filter(accounts) {
+		active[acc.ID] = true
+	}
+
+	now := time.Now().In(m.cfg.Location())
+	since := now.Add(-lookback)
+
+	quotas, err := m.storage.GetAllModelQuotas()
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := m.storage.GetQuotaSnapshots(since)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := m.storage.CountRequests(since)
+	if err != nil {
+		return nil, err
+	}
+
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	drains := quotaDrains(snapshots, active)
+
+	byModel := make(map[string]*ModelQuotaForecast)
+	var models []string
+	for _, q := range quotas {
+		if !active[q.AccountID] {
+			continue
+		}
+		f, ok := byModel[q.Model]
+		if !ok {
+			f = &ModelQuotaForecast{Model: q.Model}
+			byModel[q.Model] = f
+			models = append(models, q.Model)
+		}
+
+		remaining := q.Remaining(now)
+		f.Accounts++
+		f.Remaining += remaining
+		f.CapacityToday += remaining
+		if q.ResetAt.After(now) {
+			if f.NextResetAt.IsZero() || q.ResetAt.Before(f.NextResetAt) {
+				f.NextResetAt = q.ResetAt
+			}
+			if q.ResetAt.Before(midnight) {
+				f.CapacityToday += 1 - remaining
+			}
+		}
+		f.RequestsPerHour += float64(counts[q.AccountID][q.Model]) / lookback.Hours()
+	}
+
+	sort.Strings(models)
+	forecasts := make([]ModelQuotaForecast, 0, len(models))
+	for _, model := range models {
+		f := byModel[model]
+		if d, ok := drains[model]; ok && d.span >= minForecastSpan {
+			f.DrainPerHour = d.used / d.span.Hours()
+		}
+
+		if f.DrainPerHour > 0 {
+			f.ExhaustedAt = now.Add(time.Duration(f.Remaining / f.DrainPerHour * float64(time.Hour)))
+			f.RemainingRequests = int(f.Remaining * f.RequestsPerHour / f.DrainPerHour)
+			f.DemandToday = f.DrainPerHour * midnight.Sub(now).Hours()
+		}
+
+		switch {
+		case f.DemandToday > f.CapacityToday:
+			f.Warning = fmt.Sprintf("projected demand until midnight (%.2f accounts) exceeds remaining capacity (%.2f accounts), exhausted around %s",
+				f.DemandToday, f.CapacityToday, f.ExhaustedAt.Format("15:04"))
+		case f.DrainPerHour > 0 && f.ExhaustedAt.Before(f.NextResetAt):
+			f.Warning = fmt.Sprintf("pool runs out around %s, before the next reset at %s",
+				f.ExhaustedAt.Format("15:04"), f.NextResetAt.In(now.Location()).Format("15:04"))
+		}
+		forecasts = append(forecasts, *f)
+	}
+	return forecasts, nil
+}
+
+// quotaDrains sums the quota each model lost between consecutive snapshots of
+// the same account. Rises from a reset are skipped.
+func quotaDrains(snapshots []ModelQuota, active map[int64]bool) map[string]*modelDrain {
+	drains := make(map[string]*modelDrain)
+	for i := 0; i < len(snapshots); {
+		first := snapshots[i]
+		j := i + 1
+		for j < len(snapshots) && snapshots[j].AccountID == first.AccountID && snapshots[j].Model == first.Model {
+			j++
+		}
+		series := snapshots[i:j]
+		i = j
+		if !active[first.AccountID] {
+			continue
+		}
+
+		d, ok := drains[first.Model]
+		if !ok {
+			d = &modelDrain{}
+			drains[first.Model] = d
+		}
+		for k := 1; k < len(series); k++ {
+			prev, cur := series[k-1], series[k]
+			if !prev.ResetAt.IsZero() && !prev.ResetAt.After(cur.FetchedAt) {
+				continue
+			}
+			if used := prev.RemainingFraction - cur.RemainingFraction; used > 0 {
+				d.used += used
+			}
+		}
+		if span := series[len(series)-1].FetchedAt.Sub(first.FetchedAt); span > d.span {
+			d.span = span
+		}
+	}
+	return drains
+}
@@ -1,42 +1,163 @@
 package account
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log"
+	"math"
 	"net/http"
 	"net/url"
 	"os"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"antigravity-lite/config"
+	"antigravity-lite/internal/quota"
+	"antigravity-lite/internal/redact"
 )
 
 // Manager handles account operations
 type Manager struct {
 	storage        *Storage
+	cfg            *config.Config
 	currentIndex   int
 	mu             sync.RWMutex
 	rateLimiter    *RateLimitTracker
 	sessionManager *SessionManager
+	perf           *PerformanceTracker
+	breakers       *BreakerSet
+	concurrency    *ConcurrencyTracker
+	pacing         *PacingTracker
+	usage          *UsageTracker
+	quotaFetcher   *quota.QuotaFetcher
+	refreshes      refreshGroup
+	cache          accountCache        // guarded by mu
+	lastUsed       map[int64]time.Time // unflushed last used times, guarded by mu
+	flushMu        sync.Mutex          // serializes runtime state flushes
+	onEvent        atomic.Pointer[func(Event)]
 }
 
 // NewManager creates a new account manager
-func NewManager(storage *Storage) *Manager {
+func NewManager(storage *Storage, cfg *config.Config) *Manager {
 	m := &Manager{
 		storage:        storage,
+		cfg:            cfg,
 		currentIndex:   0,
 		rateLimiter:    NewRateLimitTracker(),
 		sessionManager: NewSessionManager(60 * time.Minute), // 60 min session TTL
+		perf:           NewPerformanceTracker(),
+		breakers:       NewBreakerSet(),
+		concurrency:    NewConcurrencyTracker(),
+		pacing:         NewPacingTracker(),
+		usage:          NewUsageTracker(),
+		quotaFetcher:   quota.NewQuotaFetcher(),
+		lastUsed:       make(map[int64]time.Time),
 	}
 
+	m.loadRuntimeState()
+
 	// Start cleanup goroutine
 	go m.periodicCleanup()
+	go m.persistLoop()
+	go m.refreshLoop()
 
 	return m
 }
 
+// stateFlushInterval is how often rate limit and session changes are written
+const stateFlushInterval = 2 * time.Second
+
+// loadRuntimeState restores rate limits and session bindings saved by a
+// previous run, dropping entries that expired while it was down
+func (m *Manager) loadRuntimeState() {
+	now := time.Now()
+
+	limits, err := m.storage.LoadRateLimits(now)
+	if err != nil {
+		log.Printf("Failed to load rate limits: %v", err)
+	}
+	m.rateLimiter.restore(limits)
+
+	bindings, err := m.storage.LoadSessionBindings(now)
+	if err != nil {
+		log.Printf("Failed to load session bindings: %v", err)
+	}
+	m.sessionManager.restore(bindings)
+
+	if len(limits) > 0 || len(bindings) > 0 {
+		log.Printf("Restored %d rate limits and %d session bindings", len(limits), len(bindings))
+	}
+
+	m.restorePacing(now)
+
+	usage, err := m.storage.LoadTokenUsage(now.Add(-24 * time.Hour))
+	if err != nil {
+		log.Printf("Failed to load token usage: %v", err)
+	}
+	m.usage.restore(usage)
+}
+
+// restorePacing seeds the pacing history of paced accounts from the last
+// day of request logs so caps survive a restart
+func (m *Manager) restorePacing(now time.Time) {
+	accounts, err := m.storage.List()
+	if err != nil {
+		log.Printf("Failed to load pacing history: %v", err)
+		return
+	}
+	history, err := m.storage.LoadRequestTimes(now.Add(-24 * time.Hour))
+	if err != nil {
+		log.Printf("Failed to load pacing history: %v", err)
+		return
+	}
+
+	paced := make(map[int64][]time.Time)
+	for _, acc := range accounts {
+		if acc.Pacing != nil && len(history[acc.ID]) > 0 {
+			paced[acc.ID] = history[acc.ID]
+		}
+	}
+	m.pacing.restore(paced)
+}
+
+// persistLoop writes rate limit and session changes behind the request path,
+// batching everything that changed within a flush interval
+func (m *Manager) persistLoop() {
+	ticker := time.NewTicker(stateFlushInterval)
+	for range ticker.C {
+		m.flushRuntimeState()
+	}
+}
+
+// flushRuntimeState writes pending rate limit, session and last used changes
+func (m *Manager) flushRuntimeState() {
+	m.flushMu.Lock()
+	defer m.flushMu.Unlock()
+
+	m.flushLastUsed()
+
+	limits, removedLimits := m.rateLimiter.takeDirty()
+	bindings, removedBindings := m.sessionManager.takeDirty()
+	if len(limits)+len(removedLimits)+len(bindings)+len(removedBindings) == 0 {
+		return
+	}
+
+	if err := m.storage.SaveRuntimeState(limits, removedLimits, bindings, removedBindings); err != nil {
+		log.Printf("Failed to persist rate limits and sessions: %v", err)
+	}
+}
+
+// Close flushes pending state. Call it before closing the storage.
+func (m *Manager) Close() {
+	m.flushRuntimeState()
+}
+
 // periodicCleanup cleans up expired rate limits and sessions
 func (m *Manager) periodicCleanup() {
 	ticker := time.NewTicker(5 * time.Minute)
@@ -48,12 +169,45 @@ func (m *Manager) periodicCleanup() {
 
 // List returns all accounts
 func (m *Manager) List() ([]Account, error) {
-	return m.storage.List()
+	accounts, err := m.storage.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := range accounts {
+		m.fillRuntime(&accounts[i])
+	}
+	return accounts, nil
 }
 
 // Get returns an account by ID
 func (m *Manager) Get(id int64) (*Account, error) {
-	return m.storage.Get(id)
+	acc, err := m.storage.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	m.fillRuntime(acc)
+	return acc, nil
+}
+
+// fillRuntime adds in-memory state that is not persisted
+func (m *Manager) fillRuntime(acc *Account) {
+	circuit := m.breakers.Status(acc.ID)
+	acc.Circuit = &circuit
+	acc.InFlight = m.concurrency.Count(acc.ID)
+	acc.MaxConcurrency = m.maxConcurrency(acc.AccountType)
+	if acc.Pacing != nil {
+		pacing := m.pacing.Status(acc.ID, acc.Pacing, time.Now())
+		acc.PacingStatus = &pacing
+	}
+	acc.TokenUsage = m.usage.Usage(acc.ID, func(model string) *config.TokenBudgetRule {
+		return m.tokenBudget(*acc, model)
+	}, time.Now().In(m.cfg.Location()))
+
+	m.mu.RLock()
+	if t, ok := m.lastUsed[acc.ID]; ok {
+		acc.LastUsedAt = t
+	}
+	m.mu.RUnlock()
 }
 
 // Create creates a new account
@@ -61,50 +215,259 @@ func (m *Manager) Create(input AccountInput) (*Account, error) {
 	if input.AccountType == "" {
 		input.AccountType = "free"
 	}
+	if input.Pacing != nil {
+		if err := input.Pacing.Validate(); err != nil {
+			return nil, err
+		}
+	}
 	return m.storage.Create(input)
 }
 
 // Update updates an account
 func (m *Manager) Update(id int64, input AccountInput) (*Account, error) {
+	if input.Pacing != nil {
+		if err := input.Pacing.Validate(); err != nil {
+			return nil, err
+		}
+	}
 	return m.storage.Update(id, input)
 }
 
-// Delete deletes an account
+// Disable pauses an account. It is skipped by selection and status checks
+// until enabled again.
+func (m *Manager) Disable(id int64) (*Account, error) {
+	if _, err := m.storage.UpdateStatus(id, StatusDisabled); err != nil {
+		return nil, err
+	}
+	m.sessionManager.UnbindAccount(id)
+	return m.Get(id)
+}
+
+// Enable resumes a disabled account and checks its status
+func (m *Manager) Enable(id int64) (*Account, error) {
+	if err := m.storage.Enable(id); err != nil {
+		return nil, err
+	}
+	return m.CheckAccountStatus(id)
+}
+
+// SetWeight sets the scheduling weight of an account
+func (m *Manager) SetWeight(id int64, weight int) (*Account, error) {
+	if err := m.storage.UpdateWeight(id, weight); err != nil {
+		return nil, err
+	}
+	return m.Get(id)
+}
+
+// SetPacing sets the pacing policy of an account; nil removes it. Request
+// history is kept so a new policy applies to recent traffic as well.
+func (m *Manager) SetPacing(id int64, policy *PacingPolicy) (*Account, error) {
+	if policy != nil {
+		if err := policy.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.storage.UpdatePacing(id, policy); err != nil {
+		return nil, err
+	}
+	if policy == nil || policy.IsZero() {
+		m.pacing.Reset(id)
+	}
+	return m.Get(id)
+}
+
+// SetTags replaces the pools an account belongs to
+func (m *Manager) SetTags(id int64, tags []string) (*Account, error) {
+	if err := m.storage.UpdateTags(id, tags); err != nil {
+		return nil, err
+	}
+	return m.Get(id)
+}
+
+// Delete deletes an account along with its cooldowns and session bindings
 func (m *Manager) Delete(id int64) error {
-	return m.storage.Delete(id)
+	if err := m.storage.Delete(id); err != nil {
+		return err
+	}
+	m.rateLimiter.ClearRateLimit(id, "")
+	m.sessionManager.UnbindAccount(id)
+	m.breakers.Reset(id)
+	m.pacing.Reset(id)
+	m.usage.Reset(id)
+	return nil
+}
+
+// RateLimitedError is returned when every usable account is rate limited
+// for longer than the configured max_wait_time
+type RateLimitedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("all accounts are rate limited or busy, retry after %ds", int(math.Ceil(e.RetryAfter.Seconds())))
 }
 
 // GetNextActive returns the next active account using intelligent selection
-// Priority: 1. Session-bound account (if valid)
-//  2. Non-rate-limited account with highest quota
-//  3. Any available account
+// Priority: 1. Session-bound account (if valid and the mode is sticky)
+//  2. Non-rate-limited account in the order of the current schedule mode
+//  3. Wait for the earliest rate limit reset, up to max_wait_time
+//
+// Without a model only account-wide rate limits are considered.
 func (m *Manager) GetNextActive() (*Account, error) {
-	return m.GetNextActiveWithSession("")
+	return m.GetNextActiveWithSession(context.Background(), "", "", nil)
+}
+
+// Scheduler returns the scheduler for the configured schedule mode.
+// It is resolved on every call so mode changes apply without a restart.
+func (m *Manager) Scheduler() Scheduler {
+	mode := ""
+	if m.cfg != nil {
+		mode = m.cfg.Proxy.ScheduleMode
+	}
+	return NewScheduler(mode, m.perf)
+}
+
+// maxWait returns how long a request may wait for a rate-limited account
+func (m *Manager) maxWait() time.Duration {
+	if m.cfg == nil || m.cfg.Proxy.MaxWaitTime <= 0 {
+		return 0
+	}
+	return time.Duration(m.cfg.Proxy.MaxWaitTime) * time.Second
+}
+
+// maxConcurrency returns the in-flight request limit for an account type;
+// 0 means unlimited
+func (m *Manager) maxConcurrency(accountType string) int {
+	if m.cfg == nil {
+		return 0
+	}
+	if n, ok := m.cfg.Proxy.MaxConcurrencyByType[accountType]; ok {
+		return n
+	}
+	return m.cfg.Proxy.MaxConcurrency
+}
+
+// tokenBudget returns the token budget rule for an account and model, nil
+// when none applies
+func (m *Manager) tokenBudget(acc Account, model string) *config.TokenBudgetRule {
+	if m.cfg == nil {
+		return nil
+	}
+	return matchBudget(m.cfg.TokenBudgets, acc, model)
+}
+
+// saturatedWait is how long selection waits before re-checking accounts that
+// are at their concurrency limit, unless a slot is released earlier
+const saturatedWait = time.Second
+
+// GetNextActiveWithSession returns the next active account for a model with
+// session stickiness, restricted to the pools in the filter. Accounts are
+// skipped when they are rate limited for that model or at their concurrency
+// limit. If every candidate is unavailable it waits for the earliest reset or
+// released slot as long as that is within max_wait_time, and returns early if
+// ctx is cancelled. Otherwise it fails fast with a *RateLimitedError.
+//
+// The returned account holds a concurrency slot; callers must call Release
+// with its ID when the request finishes.
+func (m *Manager) GetNextActiveWithSession(ctx context.Context, sessionID, model string, pools PoolFilter) (*Account, error) {
+	deadline := time.Now().Add(m.maxWait())
+
+	for {
+		released := m.concurrency.Released()
+		acc, wait, err := m.selectAccount(sessionID, model, pools)
+		var noAccounts *NoAccountsError
+		if errors.As(err, &noAccounts) {
+			m.emit(Event{Kind: EventNoAccounts, Pools: noAccounts.Pools})
+		}
+		if err != nil || acc != nil {
+			return acc, err
+		}
+
+		if time.Now().Add(wait).After(deadline) {
+			return nil, &RateLimitedError{RetryAfter: wait}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-released:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// Release frees the concurrency slot taken when an account was selected
+func (m *Manager) Release(id int64) {
+	m.concurrency.Release(id)
 }
 
-// GetNextActiveWithSession returns the next active account with session stickiness
-func (m *Manager) GetNextActiveWithSession(sessionID string) (*Account, error) {
+// selectAccount picks an account without blocking. When every candidate is
+// rate limited for the model or saturated it returns no account and the time
+// until the earliest one frees up.
+func (m *Manager) selectAccount(sessionID, model string, pools PoolFilter) (*Account, time.Duration, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	accounts, err := m.storage.GetActiveAccounts()
+	accounts, err := m.activeAccounts()
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	if len(accounts) == 0 {
-		return nil, errors.New("no active accounts available")
+		return nil, 0, &NoAccountsError{}
+	}
+
+	accounts = pools.filter(accounts)
+	if len(accounts) == 0 {
+		return nil, 0, &NoAccountsError{Pools: pools}
+	}
+
+	// Per-model quota from the last fetch; selection degrades to rate limits only
+	// if it cannot be loaded
+	var quotas map[int64]ModelQuota
+	if model != "" {
+		quotas = m.modelQuotas(model)
+	}
+	now := time.Now()
+	var quota QuotaFunc
+	if len(quotas) > 0 {
+		sortByModelQuota(accounts, quotas, now)
+		quota = func(id int64) float64 {
+			if q, ok := quotas[id]; ok {
+				return q.Remaining(now)
+			}
+			return 1
+		}
+	}
+
+	sched := m.Scheduler()
+	accounts = sched.Order(accounts, quota)
+	if !sched.Sticky() {
+		sessionID = ""
 	}
 
 	// 1. Check session binding first (for stability)
 	if sessionID != "" {
 		if boundAccountID, ok := m.sessionManager.GetBoundAccount(sessionID); ok {
-			// Check if bound account is still active and not rate limited
 			for _, acc := range accounts {
-				if acc.ID == boundAccountID && !m.rateLimiter.IsRateLimited(acc.ID) {
-					// Reuse bound account
-					_ = m.storage.UpdateLastUsed(acc.ID)
-					return &acc, nil
+				if acc.ID != boundAccountID {
+					continue
+				}
+				wait := m.unavailableFor(acc, model, quotas, now)
+				if wait == 0 {
+					// Reuse bound account and extend the binding
+					m.acquire(acc, now)
+					m.sessionManager.BindSession(sessionID, acc.ID)
+					return &acc, 0, nil
+				}
+				// Cache-first waits for the bound account rather than losing the cache
+				if sched.WaitForBound() {
+					if wait <= m.maxWait() {
+						return nil, wait, nil
+					}
 				}
 			}
 			// Bound account is no longer valid, unbind
@@ -112,39 +475,183 @@ func (m *Manager) GetNextActiveWithSession(sessionID string) (*Account, error) {
 		}
 	}
 
-	// 2. Find best non-rate-limited account (already sorted by tier/quota)
+	// 2. Find best account that is neither rate limited nor out of quota
+	// for the model (ordered by the scheduler)
 	for _, acc := range accounts {
-		if !m.rateLimiter.IsRateLimited(acc.ID) {
+		if m.unavailableFor(acc, model, quotas, now) == 0 {
+			m.acquire(acc, now)
 			// Bind to session if provided
 			if sessionID != "" {
 				m.sessionManager.BindSession(sessionID, acc.ID)
 			}
-			_ = m.storage.UpdateLastUsed(acc.ID)
-			return &acc, nil
+			return &acc, 0, nil
 		}
 	}
 
-	// 3. All accounts rate limited - find the one with shortest wait
-	var bestAccount *Account
-	minWait := int(^uint(0) >> 1) // Max int
-
-	for i := range accounts {
-		wait := m.rateLimiter.GetRemainingWait(accounts[i].ID)
-		if wait < minWait {
+	// 3. All accounts unavailable - report the shortest wait
+	minWait := time.Duration(math.MaxInt64)
+	for _, acc := range accounts {
+		if wait := m.unavailableFor(acc, model, quotas, now); wait < minWait {
 			minWait = wait
-			bestAccount = &accounts[i]
 		}
 	}
 
-	if bestAccount != nil {
-		if sessionID != "" {
-			m.sessionManager.BindSession(sessionID, bestAccount.ID)
+	return nil, minWait, nil
+}
+
+// acquire takes a concurrency slot and lets the circuit breaker know the
+// account was selected. Must be called with m.mu held.
+func (m *Manager) acquire(acc Account, now time.Time) {
+	m.concurrency.TryAcquire(acc.ID, m.maxConcurrency(acc.AccountType))
+	m.breakers.Acquire(acc.ID, now)
+	m.pacing.Record(acc.ID, acc.Pacing, now)
+	m.touch(acc.ID, now)
+}
+
+// unavailableFor returns how long an account cannot serve a model because of
+// a rate limit, an open circuit breaker, exhausted fetched quota, its pacing
+// policy or its concurrency limit
+func (m *Manager) unavailableFor(acc Account, model string, quotas map[int64]ModelQuota, now time.Time) time.Duration {
+	wait := m.rateLimiter.RemainingWait(acc.ID, model)
+	if bw := m.breakers.Wait(acc.ID, now); bw > wait {
+		wait = bw
+	}
+	if q, ok := quotas[acc.ID]; ok && q.Exhausted(now) {
+		if qw := q.ResetAt.Sub(now); qw > wait {
+			wait = qw
+		}
+	}
+	if pw := m.pacing.Wait(acc.ID, acc.Pacing, now); pw > wait {
+		wait = pw
+	}
+	if uw := m.usage.Wait(acc.ID, model, m.tokenBudget(acc, model), now.In(m.cfg.Location())); uw > wait {
+		wait = uw
+	}
+	if wait == 0 && m.concurrency.Saturated(acc.ID, m.maxConcurrency(acc.AccountType)) {
+		wait = saturatedWait
+	}
+	return wait
+}
+
+// sortByModelQuota orders accounts within each tier by the remaining quota
+// for the requested model. Accounts without quota data go last in their tier.
+func sortByModelQuota(accounts []Account, quotas map[int64]ModelQuota, now time.Time) {
+	remaining := func(id int64) float64 {
+		if q, ok := quotas[id]; ok {
+			return q.Remaining(now)
+		}
+		return -1
+	}
+	sort.SliceStable(accounts, func(i, j int) bool {
+		ti, tj := tierRank(accounts[i].AccountType), tierRank(accounts[j].AccountType)
+		if ti != tj {
+			return ti < tj
+		}
+		return remaining(accounts[i].ID) > remaining(accounts[j].ID)
+	})
+}
+
+// tierRank matches the tier order of GetActiveAccounts (ultra > pro > free)
+func tierRank(accountType string) int {
+	switch accountType {
+	case "ultra":
+		return 1
+	case "pro":
+		return 2
+	case "free":
+		return 3
+	default:
+		return 4
+	}
+}
+
+// RecordQuota stores the fetched per-model quota of an account and updates
+// the account-level quota used for ordering: remaining percent averaged over
+// models, reset at the earliest model reset
+func (m *Manager) RecordQuota(id int64, quotas []ModelQuota) error {
+	if err := m.storage.SaveModelQuotas(id, quotas); err != nil {
+		return err
+	}
+	if len(quotas) == 0 {
+		return nil
+	}
+
+	var total float64
+	var resetAt time.Time
+	for _, q := range quotas {
+		total += q.RemainingFraction
+		if !q.ResetAt.IsZero() && (resetAt.IsZero() || q.ResetAt.Before(resetAt)) {
+			resetAt = q.ResetAt
+		}
+	}
+	remaining := int64(math.Round(total / float64(len(quotas)) * 100))
+
+	return m.storage.UpdateQuota(id, 100-remaining, 100, resetAt)
+}
+
+// GetQuotas returns the last fetched per-model quota of an account
+func (m *Manager) GetQuotas(id int64) ([]ModelQuota, error) {
+	return m.storage.GetAccountQuotas(id)
+}
+
+// QuotaHistory returns the quota snapshots of an account since a time,
+// optionally for one model
+func (m *Manager) QuotaHistory(id int64, model string, since time.Time) ([]ModelQuota, error) {
+	return m.storage.GetQuotaHistory(id, model, since)
+}
+
+// QuotaSummary sums up the last fetched quota of each model over the active
+// accounts in the pools. Quota whose reset time has passed counts as full.
+func (m *Manager) QuotaSummary(pools PoolFilter) ([]ModelQuotaSummary, error) {
+	accounts, err := m.storage.GetActiveAccounts()
+	if err != nil {
+		return nil, err
+	}
+	active := make(map[int64]bool)
+	for _, acc := range pools.filter(accounts) {
+		active[acc.ID] = true
+	}
+
+	quotas, err := m.storage.GetAllModelQuotas()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	byModel := make(map[string]*ModelQuotaSummary)
+	var models []string
+	for _, q := range quotas {
+		if !active[q.AccountID] {
+			continue
+		}
+		sum, ok := byModel[q.Model]
+		if !ok {
+			sum = &ModelQuotaSummary{Model: q.Model}
+			byModel[q.Model] = sum
+			models = append(models, q.Model)
+		}
+
+		sum.Accounts++
+		sum.TotalRemaining += q.Remaining(now)
+		if q.Exhausted(now) {
+			sum.Exhausted++
+			if sum.NextResetAt.IsZero() || q.ResetAt.Before(sum.NextResetAt) {
+				sum.NextResetAt = q.ResetAt
+			}
+		}
+		if sum.OldestFetchAt.IsZero() || q.FetchedAt.Before(sum.OldestFetchAt) {
+			sum.OldestFetchAt = q.FetchedAt
 		}
-		_ = m.storage.UpdateLastUsed(bestAccount.ID)
-		return bestAccount, nil
 	}
 
-	return nil, errors.New("no accounts available")
+	sort.Strings(models)
+	summaries := make([]ModelQuotaSummary, 0, len(models))
+	for _, model := range models {
+		sum := byModel[model]
+		sum.AvgRemaining = sum.TotalRemaining / float64(sum.Accounts)
+		summaries = append(summaries, *sum)
+	}
+	return summaries, nil
 }
 
 // GetBestAccount returns the account with most remaining quota (not rate limited)
@@ -161,7 +668,7 @@ func (m *Manager) GetBestAccount() (*Account, error) {
 	// Already sorted by tier and (quota_limit - quota_used) DESC
 	// Return first non-rate-limited account
 	for i := range accounts {
-		if !m.rateLimiter.IsRateLimited(accounts[i].ID) {
+		if !m.rateLimiter.IsRateLimited(accounts[i].ID, "") {
 			return &accounts[i], nil
 		}
 	}
@@ -170,33 +677,57 @@ func (m *Manager) GetBestAccount() (*Account, error) {
 	return &accounts[0], nil
 }
 
-// CheckAccountStatus checks and updates account status
+// CheckAccountStatus checks and updates account status. The account shows as
+// checking, and is out of rotation, until the result is stored.
 func (m *Manager) CheckAccountStatus(id int64) (*Account, error) {
+	return m.checkStatus(id, true)
+}
+
+// RecheckAccountStatus checks and updates account status without marking it
+// as checking first, so the account keeps serving requests during the check.
+// Scheduled and bulk checks use it.
+func (m *Manager) RecheckAccountStatus(id int64) (*Account, error) {
+	return m.checkStatus(id, false)
+}
+
+func (m *Manager) checkStatus(id int64, markChecking bool) (*Account, error) {
 	account, err := m.storage.Get(id)
 	if err != nil {
 		return nil, err
 	}
 
-	// Mark as checking
-	_ = m.storage.UpdateStatus(id, StatusChecking)
+	// Manually disabled accounts are left alone until enabled
+	if account.Status == StatusDisabled {
+		m.fillRuntime(account)
+		return account, nil
+	}
+
+	previous := account.Status
+	if markChecking {
+		_, _ = m.storage.UpdateStatus(id, StatusChecking)
+	}
 
-	// Refresh token if needed
+	// Refresh token if needed. A transient failure keeps the previous status;
+	// the circuit breaker backs the account off instead.
 	if account.AccessToken == "" || time.Now().After(account.TokenExpiry) {
-		accessToken, expiry, err := m.refreshAccessToken(account.RefreshToken)
-		if err != nil {
-			_ = m.storage.UpdateStatus(id, StatusExpired)
-			account.Status = StatusExpired
+		if err := m.refreshToken(account); err != nil {
+			status := previous
+			if permanentRefreshError(err) {
+				status = StatusExpired
+			}
+			m.setStatus(account, status, err.Error())
+			m.fillRuntime(account)
 			return account, nil
 		}
-		_ = m.storage.UpdateToken(id, accessToken, expiry)
-		account.AccessToken = accessToken
-		account.TokenExpiry = expiry
 	}
 
 	// Test API call
 	status := m.testAPICall(account.AccessToken)
-	_ = m.storage.UpdateStatus(id, status)
-	account.Status = status
+	m.setStatus(account, status, "status check")
+	if status == StatusActive {
+		m.breakers.Reset(id)
+	}
+	m.fillRuntime(account)
 
 	return account, nil
 }
@@ -226,8 +757,8 @@ func (m *Manager) refreshAccessToken(refreshToken string) (string, time.Time, er
 	data.Set("refresh_token", refreshToken)
 	data.Set("grant_type", "refresh_token")
 
-	resp, err := http.Post(
-		"https://oauth2.googleapis.com/token",
+	resp, err := oauthClient.Post(
+		OAuthTokenURL,
 		"application/x-www-form-urlencoded",
 		strings.NewReader(data.Encode()),
 	)
@@ -239,7 +770,19 @@ func (m *Manager) refreshAccessToken(refreshToken string) (string, time.Time, er
 	body, _ := io.ReadAll(resp.Body)
 
 	if resp.StatusCode != 200 {
-		return "", time.Time{}, fmt.Errorf("token refresh failed: %d - %s", resp.StatusCode, string(body))
+		// Only keep the OAuth error code, never the raw response body
+		var oauthErr struct {
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+		}
+		_ = json.Unmarshal(body, &oauthErr)
+		if oauthErr.Error == "" {
+			oauthErr.Error = http.StatusText(resp.StatusCode)
+		}
+		return "", time.Time{}, &TokenRefreshError{
+			StatusCode: resp.StatusCode,
+			Message:    redact.String(strings.TrimSpace(oauthErr.Error + " " + oauthErr.ErrorDescription)),
+		}
 	}
 
 	var result struct {
@@ -296,6 +839,9 @@ func (m *Manager) Import(data []byte) (int, error) {
 			Email:        e.Email,
 			RefreshToken: e.RefreshToken,
 			AccountType:  e.AccountType,
+			Tags:         e.Tags,
+			Weight:       e.Weight,
+			Pacing:       e.Pacing,
 		}
 		if input.Name == "" {
 			input.Name = fmt.Sprintf("Account %d", count+1)
@@ -304,6 +850,10 @@ func (m *Manager) Import(data []byte) (int, error) {
 			input.AccountType = "free"
 		}
 
+		if input.Pacing != nil && input.Pacing.Validate() != nil {
+			input.Pacing = nil
+		}
+
 		if _, err := m.storage.Create(input); err == nil {
 			count++
 		}
@@ -326,6 +876,9 @@ func (m *Manager) Export() ([]byte, error) {
 			Email:        a.Email,
 			RefreshToken: a.RefreshToken,
 			AccountType:  a.AccountType,
+			Tags:         a.Tags,
+			Weight:       a.Weight,
+			Pacing:       a.Pacing,
 		}
 	}
 
@@ -340,7 +893,7 @@ func (m *Manager) CheckAllAccounts() error {
 	}
 
 	for _, a := range accounts {
-		_, _ = m.CheckAccountStatus(a.ID)
+		_, _ = m.RecheckAccountStatus(a.ID)
 	}
 
 	return nil
@@ -348,50 +901,125 @@ func (m *Manager) CheckAllAccounts() error {
 
 // EnsureValidToken ensures the account has a valid access token
 func (m *Manager) EnsureValidToken(account *Account) error {
-	if account.AccessToken != "" && time.Now().Before(account.TokenExpiry.Add(-5*time.Minute)) {
+	if account.AccessToken != "" && time.Now().Before(account.TokenExpiry.Add(-tokenValidMargin)) {
 		return nil // Token still valid
 	}
 
-	accessToken, expiry, err := m.refreshAccessToken(account.RefreshToken)
-	if err != nil {
-		_ = m.storage.UpdateStatus(account.ID, StatusExpired)
-		return err
-	}
-
-	_ = m.storage.UpdateToken(account.ID, accessToken, expiry)
-	account.AccessToken = accessToken
-	account.TokenExpiry = expiry
-
-	return nil
+	// Usually the background refresher got there first; concurrent requests
+	// for the same account share one refresh
+	return m.refreshToken(account)
 }
 
-// MarkAccountError marks an account as having an error
-// For 429 errors, it marks the account as rate limited temporarily
+// MarkAccountError marks an account as having an error on a model.
+// upstreamErr may be an *UpstreamError, whose headers and body are used to
+// compute the cooldown for 429 and 5xx responses.
+// For 429 errors, it sets the account aside for that model until the reported
+// reset (short for per-minute throttling, until quota reset for exhausted quota)
+// 5xx and network errors (reported as 500) also count against the account's
+// circuit breaker.
 // For 401/403 errors, it updates the account status
-func (m *Manager) MarkAccountError(id int64, statusCode int) {
+func (m *Manager) MarkAccountError(id int64, model string, statusCode int, upstreamErr error) {
+	// Other client errors say nothing about the account, but the upstream
+	// answered, which also ends a half-open probe
+	if statusCode >= 400 && statusCode < 500 && statusCode != 401 && statusCode != 403 && statusCode != 429 {
+		m.breakers.Success(id)
+		return
+	}
+
 	account, err := m.storage.Get(id)
 	if err != nil {
 		return
 	}
 
-	switch statusCode {
-	case 429:
-		// Rate limited - mark for 60 seconds default
-		// In production, parse Retry-After header from response
-		m.rateLimiter.MarkRateLimited(id, account.Email, 60)
-	case 401:
-		_ = m.storage.UpdateStatus(id, StatusExpired)
-	case 403:
-		_ = m.storage.UpdateStatus(id, StatusBanned)
-	case 500, 503:
-		// Server error - brief rate limit to avoid hammering
-		m.rateLimiter.MarkRateLimited(id, account.Email, 10)
-	}
-}
-
-// MarkAccountSuccess clears rate limit for an account after successful request
-func (m *Manager) MarkAccountSuccess(id int64) {
-	m.rateLimiter.ClearRateLimit(id)
+	m.perf.Record(id, 0, false)
+
+	var header http.Header
+	var body []byte
+	var ue *UpstreamError
+	if errors.As(upstreamErr, &ue) {
+		header, body = ue.Header, ue.Body
+	}
+
+	switch {
+	case statusCode == 429, statusCode >= 500:
+		cd := ParseCooldown(statusCode, header, body, time.Now())
+		m.rateLimiter.MarkCooldown(id, model, account.Email, cd)
+		if cd.Kind == LimitExhausted {
+			log.Printf("Account %d quota exhausted for %s (%s), set aside until %s", id, model, cd.Reason, cd.ResetAt.Format(time.RFC3339))
+		}
+		if statusCode == 429 {
+			// The upstream answered, so the account itself is reachable
+			m.breakers.Success(id)
+		} else {
+			reason := cd.Reason
+			if ue == nil && upstreamErr != nil {
+				reason = redact.Error(upstreamErr)
+			} else if reason == "" {
+				reason = fmt.Sprintf("HTTP %d", statusCode)
+			}
+			m.breakers.Failure(id, reason, time.Now())
+			if c := m.breakers.Status(id); c.State == BreakerOpen {
+				log.Printf("Account %d circuit open after %d failures, retry after %s", id, c.Failures, c.OpenUntil.Format(time.RFC3339))
+			}
+		}
+	case statusCode == 401:
+		m.setStatus(account, StatusExpired, statusReason(statusCode, upstreamErr))
+	case statusCode == 403:
+		m.setStatus(account, StatusBanned, statusReason(statusCode, upstreamErr))
+	}
+}
+
+// statusReason describes the upstream error that changed an account's status
+func statusReason(statusCode int, err error) string {
+	if err == nil {
+		return fmt.Sprintf("HTTP %d", statusCode)
+	}
+	return fmt.Sprintf("HTTP %d: %s", statusCode, redact.Error(err))
+}
+
+// GetRateLimits returns the active cooldowns of an account, one per model
+func (m *Manager) GetRateLimits(id int64) []RateLimitEntry {
+	return m.rateLimiter.List(id)
+}
+
+// LogRequest logs a request and counts its tokens against the account's
+// budget for the model
+func (m *Manager) LogRequest(entry RequestLog) error {
+	m.usage.Add(entry.AccountID, entry.Model, int64(entry.TokensIn+entry.TokensOut), time.Now())
+	return m.storage.LogRequest(entry)
+}
+
+// MarkAccountSuccess clears the account's rate limit for a model after a
+// successful request and records its latency for performance scheduling
+func (m *Manager) MarkAccountSuccess(id int64, model string, latency time.Duration) {
+	m.rateLimiter.ClearRateLimit(id, model)
+	m.breakers.Success(id)
+	m.perf.Record(id, latency, true)
+}
+
+// ListSessions returns all live session bindings
+func (m *Manager) ListSessions() []SessionBinding {
+	return m.sessionManager.List()
+}
+
+// ClearSession removes a single session binding and reports whether it
+// existed
+func (m *Manager) ClearSession(sessionID string) bool {
+	return m.sessionManager.UnbindSession(sessionID)
+}
+
+// ClearSessions removes all session bindings, or only those bound to an
+// account when accountID > 0. It returns the number of bindings removed.
+func (m *Manager) ClearSessions(accountID int64) int {
+	if accountID > 0 {
+		return m.sessionManager.UnbindAccount(accountID)
+	}
+	return m.sessionManager.Clear()
+}
+
+// GetPerformance returns recent latency and error rate for an account
+func (m *Manager) GetPerformance(id int64) (PerformanceStats, bool) {
+	return m.perf.Get(id)
 }
 
 // GetStorage returns the underlying storage
@@ -20,6 +20,22 @@ type Account struct {
 	QuotaUsed    int64 `json:"quota_used"`
 	QuotaLimit   int64 `json:"quota_limit"`
 	QuotaResetAt time.Time `json:"quota_reset_at"`
+
+	// Pools this account belongs to (e.g. personal, team, burner)
+	Tags []string `json:"tags"`
+
+	// Scheduling weight relative to DefaultWeight; lower weights get less load
+	Weight int `json:"weight"`
+
+	// Pacing limits how often and when the account is used; nil means none
+	Pacing *PacingPolicy `json:"pacing,omitempty"`
+
+	// Runtime state, filled in by Manager
+	Circuit        *CircuitStatus `json:"circuit,omitempty"`
+	InFlight       int            `json:"in_flight"`
+	MaxConcurrency int            `json:"max_concurrency"` // 0 means unlimited
+	PacingStatus   *PacingStatus  `json:"pacing_status,omitempty"`
+	TokenUsage     []TokenUsage   `json:"token_usage,omitempty"` // per model, last day
 }
 
 // Status represents account status
@@ -31,22 +47,36 @@ const (
 	StatusBanned   Status = "banned"
 	StatusUnknown  Status = "unknown"
 	StatusChecking Status = "checking"
+	StatusDisabled Status = "disabled" // paused manually, never changed by checks
+)
+
+// Account weight bounds
+const (
+	DefaultWeight = 100
+	MinWeight     = 1
+	MaxWeight     = 1000
 )
 
 // AccountInput represents input for creating/updating account
 type AccountInput struct {
-	Name         string `json:"name" binding:"required"`
-	Email        string `json:"email"`
-	RefreshToken string `json:"refresh_token" binding:"required"`
-	AccountType  string `json:"account_type"`
+	Name         string        `json:"name" binding:"required"`
+	Email        string        `json:"email"`
+	RefreshToken string        `json:"refresh_token" binding:"required"`
+	AccountType  string        `json:"account_type"`
+	Tags         []string      `json:"tags"`
+	Weight       int           `json:"weight"`
+	Pacing       *PacingPolicy `json:"pacing"`
 }
 
 // AccountExport represents exportable account data
 type AccountExport struct {
-	Name         string `json:"name"`
-	Email        string `json:"email"`
-	RefreshToken string `json:"refresh_token"`
-	AccountType  string `json:"account_type"`
+	Name         string        `json:"name"`
+	Email        string        `json:"email"`
+	RefreshToken string        `json:"refresh_token"`
+	AccountType  string        `json:"account_type"`
+	Tags         []string      `json:"tags,omitempty"`
+	Weight       int           `json:"weight,omitempty"`
+	Pacing       *PacingPolicy `json:"pacing,omitempty"`
 }
 
 // QuotaInfo represents quota information
@@ -58,3 +88,69 @@ type QuotaInfo struct {
 	Percentage  float64   `json:"percentage"`
 	ResetAt     time.Time `json:"reset_at"`
 }
+
+// RequestLog is one proxied request
+type RequestLog struct {
+	AccountID      int64
+	Model          string // routed model sent upstream
+	RequestedModel string // model the client asked for
+	TokensIn       int
+	TokensOut      int
+	LatencyMs      int
+	TTFTMs         int // time to first token of streamed requests, 0 otherwise
+	StatusCode     int
+	Error          string
+}
+
+// ModelQuota is the last fetched upstream quota of an account for one model
+type ModelQuota struct {
+	AccountID         int64     `json:"account_id"`
+	Model             string    `json:"model"`
+	RemainingFraction float64   `json:"remaining_fraction"`
+	Percentage        int       `json:"percentage"` // remaining, as in the quota API
+	ResetAt           time.Time `json:"reset_at"`
+	FetchedAt         time.Time `json:"fetched_at"`
+}
+
+// ModelQuotaSummary is the current quota of one model across active accounts
+type ModelQuotaSummary struct {
+	Model          string    `json:"model"`
+	Accounts       int       `json:"accounts"` // active accounts with quota data
+	Exhausted      int       `json:"exhausted"`
+	AvgRemaining   float64   `json:"avg_remaining"`   // mean remaining fraction
+	TotalRemaining float64   `json:"total_remaining"` // sum of remaining fractions, in accounts
+	NextResetAt    time.Time `json:"next_reset_at"`   // earliest reset of an exhausted account
+	OldestFetchAt  time.Time `json:"oldest_fetched_at"`
+}
+
+// ModelQuotaForecast projects when a model runs out across active accounts.
+// Quota amounts are in accounts, i.e. sums of remaining fractions.
+type ModelQuotaForecast struct {
+	Model           string    `json:"model"`
+	Accounts        int       `json:"accounts"`
+	Remaining       float64   `json:"remaining"`
+	DrainPerHour    float64   `json:"drain_per_hour"` // quota used per hour, from snapshots
+	RequestsPerHour float64   `json:"requests_per_hour"`
+	ExhaustedAt     time.Time `json:"exhausted_at"` // zero when nothing is being used
+	NextResetAt     time.Time `json:"next_reset_at"`
+	// RemainingRequests estimates how many requests the remaining quota
+	// serves at the observed quota cost per request
+	RemainingRequests int     `json:"remaining_requests"`
+	CapacityToday     float64 `json:"capacity_today"` // remaining plus quota restored by resets before midnight
+	DemandToday       float64 `json:"demand_today"`   // projected use until midnight
+	Warning           string  `json:"warning,omitempty"`
+}
+
+// Exhausted reports whether the quota is used up and has not reset yet
+func (q ModelQuota) Exhausted(now time.Time) bool {
+	return q.RemainingFraction <= 0 && q.ResetAt.After(now)
+}
+
+// Remaining returns the remaining fraction, assuming a full quota once the
+// reset time has passed
+func (q ModelQuota) Remaining(now time.Time) float64 {
+	if !q.ResetAt.IsZero() && !q.ResetAt.After(now) {
+		return 1
+	}
+	return q.RemainingFraction
+}
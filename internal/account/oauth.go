@@ -93,7 +93,7 @@ func (h *OAuthHandler) ExchangeCode(code string, redirectURI string) (*TokenResp
 	data.Set("redirect_uri", redirectURI)
 	data.Set("grant_type", "authorization_code")
 
-	resp, err := http.Post(
+	resp, err := oauthClient.Post(
 		OAuthTokenURL,
 		"application/x-www-form-urlencoded",
 		strings.NewReader(data.Encode()),
@@ -181,7 +181,7 @@ func (h *OAuthHandler) ProcessCallback(code string, redirectURI string) (*Accoun
 	account.TokenExpiry = expiry
 	account.Status = StatusActive
 
-	_ = h.manager.storage.UpdateStatus(account.ID, StatusActive)
+	_, _ = h.manager.storage.UpdateStatus(account.ID, StatusActive)
 
 	return account, nil
 }
@@ -0,0 +1,291 @@
+package account
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PacingPolicy spreads an account's traffic out so it looks less automated.
+// Zero fields are not enforced.
+type PacingPolicy struct {
+	DailyCap  int `json:"daily_cap"`  // requests per rolling 24h
+	HourlyCap int `json:"hourly_cap"` // requests per rolling hour
+	MinGap    int `json:"min_gap"`    // seconds between requests
+	Jitter    int `json:"jitter"`     // up to this many seconds added to each gap
+	// Window limits use to a time of day, e.g. "08:00-23:00"; windows may
+	// wrap past midnight. Timezone is an IANA name, UTC when empty.
+	Window   string `json:"window,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+
+	loc *time.Location // resolved Timezone, set by Validate
+}
+
+// PacingStatus is a snapshot of an account's pacing state
+type PacingStatus struct {
+	Requests24h      int       `json:"requests_24h"`
+	RequestsLastHour int       `json:"requests_1h"`
+	NextAllowedAt    time.Time `json:"next_allowed_at"` // zero when usable now
+	InWindow         bool      `json:"in_window"`
+}
+
+// IsZero reports whether the policy enforces nothing
+func (p PacingPolicy) IsZero() bool {
+	p.loc = nil
+	return p == PacingPolicy{}
+}
+
+// Validate checks the caps, window and timezone, and resolves the timezone
+// so selection does not load it on every request
+func (p *PacingPolicy) Validate() error {
+	if p.DailyCap < 0 || p.HourlyCap < 0 || p.MinGap < 0 || p.Jitter < 0 {
+		return fmt.Errorf("pacing values must not be negative")
+	}
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid timezone %q", p.Timezone)
+	}
+	p.loc = loc
+	if p.Window != "" {
+		if _, _, err := parseWindow(p.Window); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// windowWait returns how long until now falls inside the usage window
+func (p PacingPolicy) windowWait(now time.Time) time.Duration {
+	if p.Window == "" {
+		return 0
+	}
+	start, end, err := parseWindow(p.Window)
+	if err != nil {
+		return 0
+	}
+	loc := p.loc
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	local := now.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	elapsed := local.Sub(midnight)
+
+	inside := elapsed >= start && elapsed < end
+	if start > end { // wraps past midnight
+		inside = elapsed >= start || elapsed < end
+	}
+	if inside {
+		return 0
+	}
+
+	next := midnight.Add(start)
+	if !next.After(local) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next.Sub(local)
+}
+
+// parseWindow parses "HH:MM-HH:MM" into offsets from midnight
+func parseWindow(window string) (time.Duration, time.Duration, error) {
+	from, to, ok := strings.Cut(window, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid window %q, expected HH:MM-HH:MM", window)
+	}
+	start, err := parseClock(strings.TrimSpace(from))
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parseClock(strings.TrimSpace(to))
+	if err != nil {
+		return 0, 0, err
+	}
+	if start == end {
+		return 0, 0, fmt.Errorf("invalid window %q, start equals end", window)
+	}
+	return start, end, nil
+}
+
+// parseClock parses "HH:MM"; "24:00" is allowed as an end of day
+func parseClock(s string) (time.Duration, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil || h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+// encodePacing stores a policy as JSON; an empty policy is stored as ""
+func encodePacing(p *PacingPolicy) string {
+	if p == nil || p.IsZero() {
+		return ""
+	}
+	data, _ := json.Marshal(p)
+	return string(data)
+}
+
+// decodePacing parses a stored policy. A policy that no longer validates,
+// e.g. a timezone missing from this host's zoneinfo, is logged and applied
+// in UTC.
+func decodePacing(s string) *PacingPolicy {
+	if s == "" {
+		return nil
+	}
+	var p PacingPolicy
+	if err := json.Unmarshal([]byte(s), &p); err != nil || p.IsZero() {
+		return nil
+	}
+	if err := p.Validate(); err != nil {
+		log.Printf("Pacing policy %s: %v, using UTC", s, err)
+	}
+	return &p
+}
+
+// accountPace is the recent request history of one account
+type accountPace struct {
+	requests  []time.Time // selections within the last 24h, oldest first
+	nextAfter time.Time   // end of the current min gap plus jitter
+}
+
+// PacingTracker records when accounts with a pacing policy were used and
+// computes how long the policy keeps them out of rotation
+type PacingTracker struct {
+	mu    sync.Mutex
+	paces map[int64]*accountPace
+}
+
+// NewPacingTracker creates an empty tracker
+func NewPacingTracker() *PacingTracker {
+	return &PacingTracker{
+		paces: make(map[int64]*accountPace),
+	}
+}
+
+// Wait returns how long the policy keeps the account from serving another
+// request: the usage window, the caps and the min gap, whichever is longest
+func (t *PacingTracker) Wait(accountID int64, policy *PacingPolicy, now time.Time) time.Duration {
+	if policy == nil {
+		return 0
+	}
+	wait := policy.windowWait(now)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.paces[accountID]
+	if !ok {
+		return wait
+	}
+	p.prune(now)
+
+	if w := p.nextAfter.Sub(now); w > wait {
+		wait = w
+	}
+	if policy.DailyCap > 0 && len(p.requests) >= policy.DailyCap {
+		// Free once the oldest request counting against the cap is a day old
+		oldest := p.requests[len(p.requests)-policy.DailyCap]
+		if w := oldest.Add(24 * time.Hour).Sub(now); w > wait {
+			wait = w
+		}
+	}
+	if policy.HourlyCap > 0 {
+		if recent := p.since(now.Add(-time.Hour)); len(recent) >= policy.HourlyCap {
+			oldest := recent[len(recent)-policy.HourlyCap]
+			if w := oldest.Add(time.Hour).Sub(now); w > wait {
+				wait = w
+			}
+		}
+	}
+	return wait
+}
+
+// Record notes that the account was selected and starts its next gap
+func (t *PacingTracker) Record(accountID int64, policy *PacingPolicy, now time.Time) {
+	if policy == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := t.pace(accountID)
+	p.prune(now)
+	p.requests = append(p.requests, now)
+
+	if policy.MinGap > 0 || policy.Jitter > 0 {
+		gap := time.Duration(policy.MinGap) * time.Second
+		if policy.Jitter > 0 {
+			gap += time.Duration(rand.Int63n(int64(policy.Jitter) * int64(time.Second)))
+		}
+		p.nextAfter = now.Add(gap)
+	}
+}
+
+// Status returns the pacing state of an account under a policy
+func (t *PacingTracker) Status(accountID int64, policy *PacingPolicy, now time.Time) PacingStatus {
+	status := PacingStatus{InWindow: policy == nil || policy.windowWait(now) == 0}
+	if wait := t.Wait(accountID, policy, now); wait > 0 {
+		status.NextAllowedAt = now.Add(wait)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p, ok := t.paces[accountID]; ok {
+		p.prune(now)
+		status.Requests24h = len(p.requests)
+		status.RequestsLastHour = len(p.since(now.Add(-time.Hour)))
+	}
+	return status
+}
+
+// Reset forgets the history of an account
+func (t *PacingTracker) Reset(accountID int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.paces, accountID)
+}
+
+// restore seeds request history, e.g. from request logs after a restart
+func (t *PacingTracker) restore(history map[int64][]time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, times := range history {
+		p := t.pace(id)
+		p.requests = append(p.requests, times...)
+	}
+}
+
+func (t *PacingTracker) pace(accountID int64) *accountPace {
+	p, ok := t.paces[accountID]
+	if !ok {
+		p = &accountPace{}
+		t.paces[accountID] = p
+	}
+	return p
+}
+
+// prune drops requests older than 24h
+func (p *accountPace) prune(now time.Time) {
+	cutoff := now.Add(-24 * time.Hour)
+	i := 0
+	for i < len(p.requests) && !p.requests[i].After(cutoff) {
+		i++
+	}
+	if i > 0 {
+		p.requests = append(p.requests[:0], p.requests[i:]...)
+	}
+}
+
+// since returns the requests made after t
+func (p *accountPace) since(t time.Time) []time.Time {
+	i := len(p.requests)
+	for i > 0 && p.requests[i-1].After(t) {
+		i--
+	}
+	return p.requests[i:]
+}
@@ -0,0 +1,138 @@
+package account
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestPacingWindowWait(t *testing.T) {
+	at := func(clock string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04", "2026-03-01 "+clock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		name     string
+		window   string
+		timezone string
+		now      time.Time // UTC
+		want     time.Duration
+	}{
+		{"no window", "", "", at("03:00"), 0},
+		{"before the window", "08:00-23:00", "", at("07:00"), time.Hour},
+		{"window start is inside", "08:00-23:00", "", at("08:00"), 0},
+		{"just before the end", "08:00-23:00", "", at("22:59"), 0},
+		{"window end is outside", "08:00-23:00", "", at("23:00"), 9 * time.Hour},
+		{"end of day", "08:00-24:00", "", at("23:59"), 0},
+		{"wrapping, evening side", "22:00-06:00", "", at("23:00"), 0},
+		{"wrapping, after midnight", "22:00-06:00", "", at("05:59"), 0},
+		{"wrapping, at the end", "22:00-06:00", "", at("06:00"), 16 * time.Hour},
+		{"wrapping, before the start", "22:00-06:00", "", at("21:30"), 30 * time.Minute},
+		{"timezone", "09:00-18:00", "Asia/Shanghai", at("00:30"), 30 * time.Minute},
+		{"timezone, wrapping across the UTC date", "22:00-02:00", "Asia/Shanghai", at("17:00"), 0},
+		{"timezone, after the wrapped end", "22:00-02:00", "Asia/Shanghai", at("18:00"), 20 * time.Hour},
+	}
+	for _, tt := range tests {
+		p := PacingPolicy{Window: tt.window, Timezone: tt.timezone}
+		if err := p.Validate(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := p.windowWait(tt.now); got != tt.want {
+			t.Errorf("%s: windowWait(%s) = %s, want %s", tt.name, tt.now.Format("15:04"), got, tt.want)
+		}
+	}
+}
+
+func TestPacingPolicyValidate(t *testing.T) {
+	tests := []struct {
+		policy  PacingPolicy
+		wantErr bool
+	}{
+		{PacingPolicy{}, false},
+		{PacingPolicy{Window: "8:00-23:30", Timezone: "Europe/Berlin"}, false},
+		{PacingPolicy{Window: "22:00-06:00"}, false},
+		{PacingPolicy{Window: "00:00-24:00"}, false},
+		{PacingPolicy{Window: "23:00"}, true},
+		{PacingPolicy{Window: "10:00-10:00"}, true},
+		{PacingPolicy{Window: "25:00-26:00"}, true},
+		{PacingPolicy{Window: "08:00-24:30"}, true},
+		{PacingPolicy{Window: "08:60-09:00"}, true},
+		{PacingPolicy{Timezone: "Mars/Olympus"}, true},
+		{PacingPolicy{DailyCap: -1}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.policy.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) = %v, want error %v", tt.policy, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPacingTrackerWait(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		policy   PacingPolicy
+		requests []time.Duration // after t0
+		now      time.Duration   // after t0
+		want     time.Duration
+	}{
+		{"no history", PacingPolicy{DailyCap: 1}, nil, 0, 0},
+		{"under the daily cap", PacingPolicy{DailyCap: 3}, []time.Duration{0, time.Hour}, 2 * time.Hour, 0},
+		{"daily cap frees a day after the oldest", PacingPolicy{DailyCap: 2}, []time.Duration{0, time.Hour}, 2 * time.Hour, 22 * time.Hour},
+		{"daily cap is rolling", PacingPolicy{DailyCap: 2}, []time.Duration{0, time.Hour}, 24 * time.Hour, 0},
+		{"hourly cap", PacingPolicy{HourlyCap: 2}, []time.Duration{0, 10 * time.Minute}, 20 * time.Minute, 40 * time.Minute},
+		{"hourly cap ignores older requests", PacingPolicy{HourlyCap: 2}, []time.Duration{0, 50 * time.Minute}, 70 * time.Minute, 0},
+		{"min gap", PacingPolicy{MinGap: 60}, []time.Duration{0}, 10 * time.Second, 50 * time.Second},
+		{"min gap passed", PacingPolicy{MinGap: 60}, []time.Duration{0}, time.Minute, 0},
+		{"longest limit wins", PacingPolicy{MinGap: 600, HourlyCap: 1}, []time.Duration{0}, time.Minute, 59 * time.Minute},
+	}
+	for _, tt := range tests {
+		tracker := NewPacingTracker()
+		for _, r := range tt.requests {
+			tracker.Record(1, &tt.policy, t0.Add(r))
+		}
+		if got := tracker.Wait(1, &tt.policy, t0.Add(tt.now)); got != tt.want {
+			t.Errorf("%s: Wait = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPacingJitter(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	policy := &PacingPolicy{MinGap: 30, Jitter: 10}
+	tracker := NewPacingTracker()
+
+	for i := 0; i < 50; i++ {
+		tracker.Record(1, policy, t0)
+		wait := tracker.Wait(1, policy, t0)
+		if wait < 30*time.Second || wait >= 40*time.Second {
+			t.Fatalf("gap = %s, want between 30s and 40s", wait)
+		}
+	}
+}
+
+func TestDecodePacingResolvesTimezone(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 30, 0, 0, time.UTC) // 08:30 in Shanghai
+
+	p := decodePacing(`{"window": "09:00-18:00", "timezone": "Asia/Shanghai"}`)
+	if p == nil || p.loc == nil || p.loc.String() != "Asia/Shanghai" {
+		t.Fatalf("decoded policy %+v, want the timezone resolved", p)
+	}
+	if got := p.windowWait(now); got != 30*time.Minute {
+		t.Errorf("windowWait = %s, want 30m", got)
+	}
+
+	// A zone this host cannot load is applied in UTC
+	p = decodePacing(`{"window": "09:00-18:00", "timezone": "Mars/Olympus"}`)
+	if p == nil {
+		t.Fatal("policy with an unknown timezone was dropped")
+	}
+	if got := p.windowWait(now); got != 8*time.Hour+30*time.Minute {
+		t.Errorf("windowWait = %s, want 8h30m in UTC", got)
+	}
+}
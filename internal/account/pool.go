@@ -0,0 +1,73 @@
+package account
+
+import (
+	"sort"
+	"strings"
+)
+
+// PoolFilter restricts account selection to pools (account tags).
+// Each group is one restriction, e.g. from a route or a client key, and an
+// account qualifies if it carries at least one tag from every non-empty group.
+type PoolFilter [][]string
+
+// Allows reports whether an account with the given tags may be used
+func (f PoolFilter) Allows(tags []string) bool {
+	for _, group := range f {
+		if len(group) > 0 && !hasAnyTag(tags, group) {
+			return false
+		}
+	}
+	return true
+}
+
+// String describes the filter for error messages
+func (f PoolFilter) String() string {
+	var parts []string
+	for _, group := range f {
+		if len(group) > 0 {
+			parts = append(parts, strings.Join(group, "|"))
+		}
+	}
+	return strings.Join(parts, " & ")
+}
+
+// filter returns the accounts allowed by f
+func (f PoolFilter) filter(accounts []Account) []Account {
+	if len(f) == 0 {
+		return accounts
+	}
+	allowed := accounts[:0]
+	for _, acc := range accounts {
+		if f.Allows(acc.Tags) {
+			allowed = append(allowed, acc)
+		}
+	}
+	return allowed
+}
+
+func hasAnyTag(tags, pools []string) bool {
+	for _, t := range tags {
+		for _, p := range pools {
+			if strings.EqualFold(t, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NormalizeTags trims, lowercases, dedupes and sorts tags
+func NormalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	out := make([]string, 0, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || strings.Contains(t, ",") || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
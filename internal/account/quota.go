@@ -0,0 +1,76 @@
+package account
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"antigravity-lite/internal/quota"
+)
+
+// RefreshQuota fetches the quota of an account, stores it and updates the
+// account type from the reported subscription tier
+func (m *Manager) RefreshQuota(id int64) (*quota.AccountQuota, error) {
+	acc, err := m.storage.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.EnsureValidToken(acc); err != nil {
+		return nil, fmt.Errorf("token refresh failed: %w", err)
+	}
+
+	data, err := m.quotaFetcher.FetchQuota(acc.AccessToken, "", acc.Email)
+	if err != nil {
+		return nil, fmt.Errorf("quota fetch failed: %w", err)
+	}
+
+	m.saveFetchedQuota(id, data)
+
+	if accountType := tierAccountType(data.SubscriptionTier); accountType != "" && accountType != acc.AccountType {
+		_ = m.storage.UpdateAccountType(id, accountType)
+	}
+
+	return data, nil
+}
+
+// saveFetchedQuota persists fetched per-model quota so account selection can
+// use it
+func (m *Manager) saveFetchedQuota(id int64, data *quota.AccountQuota) {
+	if data.IsForbidden {
+		return
+	}
+
+	quotas := make([]ModelQuota, 0, len(data.Models))
+	for _, mq := range data.Models {
+		q := ModelQuota{
+			AccountID:         id,
+			Model:             mq.Name,
+			RemainingFraction: mq.RemainingFraction,
+			FetchedAt:         data.FetchedAt,
+		}
+		if t, err := time.Parse(time.RFC3339, mq.ResetTime); err == nil {
+			q.ResetAt = t
+		}
+		quotas = append(quotas, q)
+	}
+
+	if err := m.RecordQuota(id, quotas); err != nil {
+		log.Printf("Failed to save quota for account %d: %v", id, err)
+	}
+}
+
+// tierAccountType maps a subscription tier to an account type; "" when no
+// tier was reported
+func tierAccountType(tier string) string {
+	switch tier {
+	case "":
+		return ""
+	case "ULTRA":
+		return "ultra"
+	case "PRO":
+		return "pro"
+	default:
+		return "free"
+	}
+}
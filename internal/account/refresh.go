@@ -0,0 +1,163 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Token refresh tuning
+const (
+	tokenRefreshTimeout  = 15 * time.Second // per OAuth token request
+	tokenRefreshAhead    = 10 * time.Minute // background refresh window before expiry
+	tokenRefreshInterval = time.Minute      // how often the background refresher runs
+	tokenRefreshWorkers  = 4                // parallel background refreshes
+	tokenValidMargin     = 5 * time.Minute  // tokens closer to expiry are refreshed inline
+)
+
+// oauthClient is used for all OAuth token requests
+var oauthClient = &http.Client{Timeout: tokenRefreshTimeout}
+
+// TokenRefreshError is returned when the OAuth server rejects a refresh
+type TokenRefreshError struct {
+	StatusCode int
+	Message    string // OAuth error code and description, redacted
+}
+
+func (e *TokenRefreshError) Error() string {
+	return fmt.Sprintf("token refresh failed: %d - %s", e.StatusCode, e.Message)
+}
+
+// Permanent reports whether the refresh token itself was rejected
+// (revoked, expired or issued to another client), so retrying cannot help
+func (e *TokenRefreshError) Permanent() bool {
+	return e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnauthorized
+}
+
+// refreshCall is an in-progress or completed token refresh
+type refreshCall struct {
+	wg     sync.WaitGroup
+	token  string
+	expiry time.Time
+	err    error
+}
+
+// refreshGroup makes concurrent refreshes of the same account share one
+// OAuth request
+type refreshGroup struct {
+	mu    sync.Mutex
+	calls map[int64]*refreshCall
+}
+
+// do runs fn for the account unless a refresh is already running, in which
+// case it waits for that one and returns its result
+func (g *refreshGroup) do(accountID int64, fn func() (string, time.Time, error)) (string, time.Time, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[int64]*refreshCall)
+	}
+	if call, ok := g.calls[accountID]; ok {
+		g.mu.Unlock()
+		call.wg.Wait()
+		return call.token, call.expiry, call.err
+	}
+	call := &refreshCall{}
+	call.wg.Add(1)
+	g.calls[accountID] = call
+	g.mu.Unlock()
+
+	call.token, call.expiry, call.err = fn()
+	call.wg.Done()
+
+	g.mu.Lock()
+	delete(g.calls, accountID)
+	g.mu.Unlock()
+
+	return call.token, call.expiry, call.err
+}
+
+// refreshToken refreshes an account's access token, sharing the request with
+// any concurrent refresh of the same account. The new token is saved and set
+// on acc. Failures go through the account's status and circuit breaker.
+func (m *Manager) refreshToken(acc *Account) error {
+	token, expiry, err := m.refreshes.do(acc.ID, func() (string, time.Time, error) {
+		token, expiry, err := m.refreshAccessToken(acc.RefreshToken)
+		if err != nil {
+			m.tokenRefreshFailed(acc, err)
+			return "", time.Time{}, err
+		}
+		_ = m.storage.UpdateToken(acc.ID, token, expiry)
+		return token, expiry, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	acc.AccessToken = token
+	acc.TokenExpiry = expiry
+	return nil
+}
+
+// tokenRefreshFailed marks the account expired when its refresh token was
+// rejected. Network errors and OAuth server errors count against the circuit
+// breaker instead, so the account backs off without losing its status.
+func (m *Manager) tokenRefreshFailed(acc *Account, err error) {
+	if permanentRefreshError(err) {
+		m.setStatus(acc, StatusExpired, "refresh token rejected: "+err.Error())
+		return
+	}
+	m.breakers.Failure(acc.ID, err.Error(), time.Now())
+}
+
+// permanentRefreshError reports whether err means the refresh token is no
+// longer usable
+func permanentRefreshError(err error) bool {
+	var refreshErr *TokenRefreshError
+	return errors.As(err, &refreshErr) && refreshErr.Permanent()
+}
+
+// refreshLoop renews tokens of active accounts before they expire so
+// requests rarely have to refresh inline
+func (m *Manager) refreshLoop() {
+	ticker := time.NewTicker(tokenRefreshInterval)
+	for range ticker.C {
+		m.refreshExpiringTokens()
+	}
+}
+
+// refreshExpiringTokens refreshes every active account whose token expires
+// within tokenRefreshAhead, skipping accounts that are backing off
+func (m *Manager) refreshExpiringTokens() {
+	accounts, err := m.storage.GetActiveAccounts()
+	if err != nil {
+		log.Printf("Token refresh: failed to list accounts: %v", err)
+		return
+	}
+
+	now := time.Now()
+	sem := make(chan struct{}, tokenRefreshWorkers)
+	var wg sync.WaitGroup
+	for i := range accounts {
+		acc := &accounts[i]
+		if acc.AccessToken != "" && acc.TokenExpiry.After(now.Add(tokenRefreshAhead)) {
+			continue
+		}
+		if m.breakers.Wait(acc.ID, now) > 0 {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := m.refreshToken(acc); err != nil {
+				log.Printf("Token refresh failed for account %d: %v", acc.ID, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
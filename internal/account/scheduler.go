@@ -0,0 +1,228 @@
+package account
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Schedule modes (ProxyConfig.ScheduleMode)
+const (
+	ScheduleCacheFirst  = "cache-first"
+	ScheduleBalance     = "balance"
+	SchedulePerformance = "performance"
+)
+
+// Scheduler decides the order in which active accounts are tried
+type Scheduler interface {
+	// Name returns the schedule mode
+	Name() string
+	// Sticky reports whether session bindings are honored
+	Sticky() bool
+	// WaitForBound reports whether a request should wait for its rate-limited
+	// bound account (within max_wait_time) instead of switching accounts
+	WaitForBound() bool
+	// Order sorts candidates by preference, most preferred first, taking
+	// account weights and the remaining quota for the requested model into
+	// account. Candidates arrive sorted by tier, remaining quota and last use.
+	Order(candidates []Account, quota QuotaFunc) []Account
+}
+
+// QuotaFunc returns an account's remaining quota fraction for the requested
+// model, 1 when unknown
+type QuotaFunc func(id int64) float64
+
+// minQuotaShare keeps accounts that are nearly out of quota comparable
+// instead of multiplying their rank by zero
+const minQuotaShare = 0.01
+
+// quotaShare returns the remaining quota used to scale an account's rank
+func quotaShare(quota QuotaFunc, id int64) float64 {
+	if quota == nil {
+		return 1
+	}
+	return math.Max(quota(id), minQuotaShare)
+}
+
+// NewScheduler returns the scheduler for a schedule mode, defaulting to balance
+func NewScheduler(mode string, perf *PerformanceTracker) Scheduler {
+	switch mode {
+	case ScheduleCacheFirst, "cache":
+		return cacheFirstScheduler{}
+	case SchedulePerformance:
+		return &performanceScheduler{perf: perf}
+	default:
+		return balanceScheduler{}
+	}
+}
+
+// cacheFirstScheduler keeps conversations on their bound account to maximize
+// prompt cache hits; new sessions go to the highest weight, then the best
+// tier with the most quota
+type cacheFirstScheduler struct{}
+
+func (cacheFirstScheduler) Name() string       { return ScheduleCacheFirst }
+func (cacheFirstScheduler) Sticky() bool       { return true }
+func (cacheFirstScheduler) WaitForBound() bool { return true }
+
+// Order keeps the incoming tier and quota order among equal weights
+func (cacheFirstScheduler) Order(candidates []Account, _ QuotaFunc) []Account {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return ClampWeight(candidates[i].Weight) > ClampWeight(candidates[j].Weight)
+	})
+	return candidates
+}
+
+// balanceScheduler spreads every request with weighted least-recently-used
+// round robin, scaling each account's weight by its remaining quota so fuller
+// accounts are picked more often. Session bindings are ignored: nearly every
+// request carries a session ID, so honoring them would make balance behave
+// like cache-first.
+type balanceScheduler struct{}
+
+func (balanceScheduler) Name() string       { return ScheduleBalance }
+func (balanceScheduler) Sticky() bool       { return false }
+func (balanceScheduler) WaitForBound() bool { return false }
+
+func (balanceScheduler) Order(candidates []Account, quota QuotaFunc) []Account {
+	now := time.Now()
+	idle := make(map[int64]float64, len(candidates))
+	for _, acc := range candidates {
+		idle[acc.ID] = weightedIdle(acc, now) * quotaShare(quota, acc.ID)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return idle[candidates[i].ID] > idle[candidates[j].ID]
+	})
+	return candidates
+}
+
+// performanceScheduler ignores sessions and prefers accounts with the lowest
+// recent latency and error rate, relative to their weight and remaining quota
+type performanceScheduler struct {
+	perf *PerformanceTracker
+}
+
+func (*performanceScheduler) Name() string       { return SchedulePerformance }
+func (*performanceScheduler) Sticky() bool       { return false }
+func (*performanceScheduler) WaitForBound() bool { return false }
+
+func (s *performanceScheduler) Order(candidates []Account, quota QuotaFunc) []Account {
+	now := time.Now()
+	scores := make(map[int64]float64, len(candidates))
+	for _, acc := range candidates {
+		scores[acc.ID] = s.perf.Score(acc.ID) / (weightOf(acc) * quotaShare(quota, acc.ID))
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		si, sj := scores[candidates[i].ID], scores[candidates[j].ID]
+		if si != sj {
+			return si < sj
+		}
+		return weightedIdle(candidates[i], now) > weightedIdle(candidates[j], now)
+	})
+	return candidates
+}
+
+// weightOf returns an account's weight relative to DefaultWeight
+func weightOf(acc Account) float64 {
+	return float64(ClampWeight(acc.Weight)) / DefaultWeight
+}
+
+// weightedIdle ranks accounts for least-recently-used rotation. Idle time is
+// scaled by weight, so an account with twice the weight is picked about twice
+// as often. Accounts never used come first.
+func weightedIdle(acc Account, now time.Time) float64 {
+	if acc.LastUsedAt.IsZero() {
+		return math.Inf(1)
+	}
+	return now.Sub(acc.LastUsedAt).Seconds() * weightOf(acc)
+}
+
+// perfEWMAWeight is the weight of the newest sample in the moving averages
+const perfEWMAWeight = 0.2
+
+// PerformanceStats holds recent latency and error rate for an account
+type PerformanceStats struct {
+	LatencyMs float64   `json:"latency_ms"`
+	ErrorRate float64   `json:"error_rate"`
+	Samples   int       `json:"samples"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PerformanceTracker keeps exponentially weighted latency and error rates per account
+type PerformanceTracker struct {
+	mu    sync.RWMutex
+	stats map[int64]*PerformanceStats
+}
+
+// NewPerformanceTracker creates a new performance tracker
+func NewPerformanceTracker() *PerformanceTracker {
+	return &PerformanceTracker{
+		stats: make(map[int64]*PerformanceStats),
+	}
+}
+
+// Record adds a request outcome. Latency is only sampled for successful requests.
+func (t *PerformanceTracker) Record(accountID int64, latency time.Duration, success bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.stats[accountID]
+	if !ok {
+		s = &PerformanceStats{}
+		t.stats[accountID] = s
+	}
+
+	errSample := 0.0
+	if !success {
+		errSample = 1
+	}
+
+	if s.Samples == 0 {
+		s.ErrorRate = errSample
+		if success {
+			s.LatencyMs = float64(latency.Milliseconds())
+		}
+	} else {
+		s.ErrorRate = s.ErrorRate*(1-perfEWMAWeight) + errSample*perfEWMAWeight
+		if success {
+			if s.LatencyMs == 0 {
+				s.LatencyMs = float64(latency.Milliseconds())
+			} else {
+				s.LatencyMs = s.LatencyMs*(1-perfEWMAWeight) + float64(latency.Milliseconds())*perfEWMAWeight
+			}
+		}
+	}
+	s.Samples++
+	s.UpdatedAt = time.Now()
+}
+
+// Score returns a lower-is-better score. Accounts without samples score 0
+// so they get tried and measured.
+func (t *PerformanceTracker) Score(accountID int64) float64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	s, ok := t.stats[accountID]
+	if !ok || s.Samples == 0 {
+		return 0
+	}
+
+	latency := s.LatencyMs
+	if latency == 0 {
+		latency = 1000 // only errors so far
+	}
+	return latency * (1 + 5*s.ErrorRate)
+}
+
+// Get returns a copy of the stats for an account
+func (t *PerformanceTracker) Get(accountID int64) (PerformanceStats, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	s, ok := t.stats[accountID]
+	if !ok {
+		return PerformanceStats{}, false
+	}
+	return *s, true
+}
@@ -0,0 +1,99 @@
+package account
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"antigravity-lite/config"
+)
+
+// newTestManager returns a manager over a fresh database with one active
+// account per entry of lastUsed, last used that long ago
+func newTestManager(t *testing.T, mode string, lastUsed ...time.Duration) (*Manager, []int64) {
+	t.Helper()
+	storage, err := NewStorage(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { storage.Close() })
+
+	now := time.Now()
+	ids := make([]int64, len(lastUsed))
+	times := make(map[int64]time.Time)
+	for i, ago := range lastUsed {
+		acc, err := storage.Create(AccountInput{Name: "acc", RefreshToken: "token", AccountType: "pro"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := storage.UpdateStatus(acc.ID, StatusActive); err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = acc.ID
+		times[acc.ID] = now.Add(-ago)
+	}
+	if err := storage.SaveLastUsed(times); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Proxy.ScheduleMode = mode
+	return NewManager(storage, cfg), ids
+}
+
+func TestBalancePrefersRemainingQuota(t *testing.T) {
+	const model = "gemini-2.5-pro"
+	resetAt := time.Now().Add(time.Hour)
+
+	// The first account has been idle longer but is nearly out of quota
+	m, ids := newTestManager(t, ScheduleBalance, 10*time.Minute, 5*time.Minute)
+	for i, remaining := range []float64{0.1, 0.9} {
+		quota := ModelQuota{Model: model, RemainingFraction: remaining, ResetAt: resetAt}
+		if err := m.RecordQuota(ids[i], []ModelQuota{quota}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	acc, _, err := m.selectAccount("", model, PoolFilter{})
+	if err != nil || acc == nil {
+		t.Fatalf("selectAccount = %v, %v", acc, err)
+	}
+	if acc.ID != ids[1] {
+		t.Errorf("selected account %d, want %d with the most remaining quota", acc.ID, ids[1])
+	}
+
+	// Without quota data the least recently used account wins
+	acc, _, err = m.selectAccount("", "unfetched-model", PoolFilter{})
+	if err != nil || acc == nil {
+		t.Fatalf("selectAccount = %v, %v", acc, err)
+	}
+	if acc.ID != ids[0] {
+		t.Errorf("selected account %d for a model without quota, want %d", acc.ID, ids[0])
+	}
+}
+
+func TestBalanceOrderScalesIdleByQuota(t *testing.T) {
+	now := time.Now()
+	candidates := []Account{
+		{ID: 1, Weight: DefaultWeight, LastUsedAt: now.Add(-4 * time.Minute)},
+		{ID: 2, Weight: DefaultWeight, LastUsedAt: now.Add(-3 * time.Minute)},
+		{ID: 3, Weight: DefaultWeight, LastUsedAt: now.Add(-2 * time.Minute)},
+	}
+	remaining := map[int64]float64{1: 0.2, 2: 1, 3: 0.5}
+
+	got := balanceScheduler{}.Order(candidates, func(id int64) float64 { return remaining[id] })
+	want := []int64{2, 3, 1} // 180s, 60s and 48s of quota-weighted idle time
+	for i, acc := range got {
+		if acc.ID != want[i] {
+			t.Fatalf("order = %v, want %v", accountIDs(got), want)
+		}
+	}
+}
+
+func accountIDs(accounts []Account) []int64 {
+	out := make([]int64, len(accounts))
+	for i, acc := range accounts {
+		out[i] = acc.ID
+	}
+	return out
+}
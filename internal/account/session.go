@@ -7,93 +7,149 @@ import (
 	"time"
 )
 
-// RateLimitEntry tracks rate limit status for an account
+// RateLimitEntry tracks rate limit status for an account on one model.
+// An empty Model means the whole account is set aside.
 type RateLimitEntry struct {
-	AccountID int64
-	Email     string
-	LimitedAt time.Time
-	ResetAt   time.Time
-	FailCount int
-	LastError string
+	AccountID int64     `json:"account_id"`
+	Email     string    `json:"email"`
+	Model     string    `json:"model,omitempty"`
+	Kind      LimitKind `json:"kind"`
+	LimitedAt time.Time `json:"limited_at"`
+	ResetAt   time.Time `json:"reset_at"`
+	FailCount int       `json:"fail_count"`
+	LastError string    `json:"last_error,omitempty"`
 }
 
-// RateLimitTracker tracks rate-limited accounts
+// rateLimitKey identifies a cooldown. Google quotas are per model, so a 429
+// on one model must not bench the account for the others.
+type rateLimitKey struct {
+	accountID int64
+	model     string
+}
+
+// RateLimitTracker tracks rate-limited (account, model) pairs
 type RateLimitTracker struct {
 	mu      sync.RWMutex
-	entries map[int64]*RateLimitEntry
+	entries map[rateLimitKey]*RateLimitEntry
+	dirty   map[rateLimitKey]struct{} // changed since the last flush
 }
 
 // NewRateLimitTracker creates a new rate limit tracker
 func NewRateLimitTracker() *RateLimitTracker {
 	return &RateLimitTracker{
-		entries: make(map[int64]*RateLimitEntry),
+		entries: make(map[rateLimitKey]*RateLimitEntry),
+		dirty:   make(map[rateLimitKey]struct{}),
 	}
 }
 
-// MarkRateLimited marks an account as rate limited
-func (t *RateLimitTracker) MarkRateLimited(accountID int64, email string, resetSeconds int) {
+// MarkRateLimited marks an account as rate limited for a model
+func (t *RateLimitTracker) MarkRateLimited(accountID int64, model, email string, resetSeconds int) {
+	t.MarkCooldown(accountID, model, email, Cooldown{
+		Kind:    LimitThrottled,
+		ResetAt: time.Now().Add(time.Duration(resetSeconds) * time.Second),
+	})
+}
+
+// MarkCooldown sets an account aside for a model until cd.ResetAt.
+// An empty model applies the cooldown to every model.
+func (t *RateLimitTracker) MarkCooldown(accountID int64, model, email string, cd Cooldown) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
 	now := time.Now()
-	resetAt := now.Add(time.Duration(resetSeconds) * time.Second)
+	key := rateLimitKey{accountID, model}
+	t.dirty[key] = struct{}{}
 
-	if entry, exists := t.entries[accountID]; exists {
+	if entry, exists := t.entries[key]; exists {
+		entry.Kind = cd.Kind
 		entry.LimitedAt = now
-		entry.ResetAt = resetAt
+		entry.ResetAt = cd.ResetAt
+		entry.LastError = cd.Reason
 		entry.FailCount++
 	} else {
-		t.entries[accountID] = &RateLimitEntry{
+		t.entries[key] = &RateLimitEntry{
 			AccountID: accountID,
 			Email:     email,
+			Model:     model,
+			Kind:      cd.Kind,
 			LimitedAt: now,
-			ResetAt:   resetAt,
+			ResetAt:   cd.ResetAt,
 			FailCount: 1,
+			LastError: cd.Reason,
 		}
 	}
 }
 
-// IsRateLimited checks if an account is currently rate limited
-func (t *RateLimitTracker) IsRateLimited(accountID int64) bool {
+// active returns the entry for key if its cooldown has not passed.
+// Callers must hold t.mu.
+func (t *RateLimitTracker) active(key rateLimitKey, now time.Time) (*RateLimitEntry, bool) {
+	entry, exists := t.entries[key]
+	if !exists || now.After(entry.ResetAt) {
+		return nil, false
+	}
+	return entry, true
+}
+
+// List returns copies of the active rate limit entries for an account
+func (t *RateLimitTracker) List(accountID int64) []RateLimitEntry {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 
-	entry, exists := t.entries[accountID]
-	if !exists {
-		return false
-	}
-
-	// Check if reset time has passed
-	if time.Now().After(entry.ResetAt) {
-		return false
+	now := time.Now()
+	var entries []RateLimitEntry
+	for key, entry := range t.entries {
+		if key.accountID == accountID && !now.After(entry.ResetAt) {
+			entries = append(entries, *entry)
+		}
 	}
+	return entries
+}
 
-	return true
+// IsRateLimited checks if an account is currently rate limited for a model,
+// either by a cooldown on that model or by an account-wide one
+func (t *RateLimitTracker) IsRateLimited(accountID int64, model string) bool {
+	return t.RemainingWait(accountID, model) > 0
 }
 
 // GetRemainingWait returns remaining wait time in seconds
-func (t *RateLimitTracker) GetRemainingWait(accountID int64) int {
+func (t *RateLimitTracker) GetRemainingWait(accountID int64, model string) int {
+	return int(t.RemainingWait(accountID, model).Seconds())
+}
+
+// RemainingWait returns the time until the account can serve the model again
+func (t *RateLimitTracker) RemainingWait(accountID int64, model string) time.Duration {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 
-	entry, exists := t.entries[accountID]
-	if !exists {
-		return 0
+	now := time.Now()
+	var resetAt time.Time
+	if entry, ok := t.active(rateLimitKey{accountID, ""}, now); ok {
+		resetAt = entry.ResetAt
+	}
+	if model != "" {
+		if entry, ok := t.active(rateLimitKey{accountID, model}, now); ok && entry.ResetAt.After(resetAt) {
+			resetAt = entry.ResetAt
+		}
 	}
 
-	remaining := time.Until(entry.ResetAt)
-	if remaining <= 0 {
+	if resetAt.IsZero() {
 		return 0
 	}
-
-	return int(remaining.Seconds())
+	return resetAt.Sub(now)
 }
 
-// ClearRateLimit clears rate limit for an account
-func (t *RateLimitTracker) ClearRateLimit(accountID int64) {
+// ClearRateLimit clears the rate limit of an account for a model, along with
+// any account-wide one. An empty model clears every model.
+func (t *RateLimitTracker) ClearRateLimit(accountID int64, model string) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
-	delete(t.entries, accountID)
+
+	for key := range t.entries {
+		if key.accountID == accountID && (model == "" || key.model == "" || key.model == model) {
+			delete(t.entries, key)
+			t.dirty[key] = struct{}{}
+		}
+	}
 }
 
 // ClearExpired removes expired rate limit entries
@@ -102,24 +158,59 @@ func (t *RateLimitTracker) ClearExpired() {
 	defer t.mu.Unlock()
 
 	now := time.Now()
-	for id, entry := range t.entries {
+	for key, entry := range t.entries {
 		if now.After(entry.ResetAt) {
-			delete(t.entries, id)
+			delete(t.entries, key)
+			t.dirty[key] = struct{}{}
+		}
+	}
+}
+
+// restore loads persisted entries, skipping those that already expired
+func (t *RateLimitTracker) restore(entries []RateLimitEntry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	for i := range entries {
+		if now.After(entries[i].ResetAt) {
+			continue
+		}
+		entry := entries[i]
+		t.entries[rateLimitKey{entry.AccountID, entry.Model}] = &entry
+	}
+}
+
+// takeDirty returns the entries changed since the last call: current values
+// to save and keys that were removed
+func (t *RateLimitTracker) takeDirty() (saved, removed []RateLimitEntry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key := range t.dirty {
+		if entry, ok := t.entries[key]; ok {
+			saved = append(saved, *entry)
+		} else {
+			removed = append(removed, RateLimitEntry{AccountID: key.accountID, Model: key.model})
 		}
 	}
+	t.dirty = make(map[rateLimitKey]struct{})
+	return saved, removed
 }
 
 // SessionBinding tracks session to account binding for stickiness
 type SessionBinding struct {
-	SessionID string
-	AccountID int64
-	BoundAt   time.Time
+	SessionID string    `json:"session_id"`
+	AccountID int64     `json:"account_id"`
+	BoundAt   time.Time `json:"bound_at"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
 // SessionManager manages session stickiness
 type SessionManager struct {
 	mu       sync.RWMutex
 	bindings map[string]*SessionBinding
+	dirty    map[string]struct{} // changed since the last flush
 	ttl      time.Duration
 }
 
@@ -127,6 +218,7 @@ type SessionManager struct {
 func NewSessionManager(ttl time.Duration) *SessionManager {
 	return &SessionManager{
 		bindings: make(map[string]*SessionBinding),
+		dirty:    make(map[string]struct{}),
 		ttl:      ttl,
 	}
 }
@@ -173,18 +265,70 @@ func (m *SessionManager) BindSession(sessionID string, accountID int64) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	now := time.Now()
+	m.dirty[sessionID] = struct{}{}
 	m.bindings[sessionID] = &SessionBinding{
 		SessionID: sessionID,
 		AccountID: accountID,
-		BoundAt:   time.Now(),
+		BoundAt:   now,
+		ExpiresAt: now.Add(m.ttl),
 	}
 }
 
-// UnbindSession removes session binding
-func (m *SessionManager) UnbindSession(sessionID string) {
+// List returns all live session bindings
+func (m *SessionManager) List() []SessionBinding {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	bindings := make([]SessionBinding, 0, len(m.bindings))
+	for _, b := range m.bindings {
+		if now.Sub(b.BoundAt) <= m.ttl {
+			bindings = append(bindings, *b)
+		}
+	}
+	return bindings
+}
+
+// UnbindAccount removes all bindings to an account
+func (m *SessionManager) UnbindAccount(accountID int64) int {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+
+	removed := 0
+	for id, b := range m.bindings {
+		if b.AccountID == accountID {
+			delete(m.bindings, id)
+			m.dirty[id] = struct{}{}
+			removed++
+		}
+	}
+	return removed
+}
+
+// Clear removes all session bindings
+func (m *SessionManager) Clear() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	removed := len(m.bindings)
+	for id := range m.bindings {
+		m.dirty[id] = struct{}{}
+	}
+	m.bindings = make(map[string]*SessionBinding)
+	return removed
+}
+
+// UnbindSession removes session binding and reports whether there was one
+func (m *SessionManager) UnbindSession(sessionID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.bindings[sessionID]; !ok {
+		return false
+	}
 	delete(m.bindings, sessionID)
+	m.dirty[sessionID] = struct{}{}
+	return true
 }
 
 // CleanupExpired removes expired session bindings
@@ -196,6 +340,39 @@ func (m *SessionManager) CleanupExpired() {
 	for id, binding := range m.bindings {
 		if now.Sub(binding.BoundAt) > m.ttl {
 			delete(m.bindings, id)
+			m.dirty[id] = struct{}{}
+		}
+	}
+}
+
+// restore loads persisted bindings, skipping those past their TTL
+func (m *SessionManager) restore(bindings []SessionBinding) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for i := range bindings {
+		if now.Sub(bindings[i].BoundAt) > m.ttl {
+			continue
+		}
+		binding := bindings[i]
+		m.bindings[binding.SessionID] = &binding
+	}
+}
+
+// takeDirty returns the bindings changed since the last call: current
+// values to save and session IDs that were removed
+func (m *SessionManager) takeDirty() (saved []SessionBinding, removed []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id := range m.dirty {
+		if b, ok := m.bindings[id]; ok {
+			saved = append(saved, *b)
+		} else {
+			removed = append(removed, id)
 		}
 	}
+	m.dirty = make(map[string]struct{})
+	return saved, removed
 }
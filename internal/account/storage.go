@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -11,7 +13,8 @@ import (
 
 // Storage handles account persistence
 type Storage struct {
-	db *sql.DB
+	db      *sql.DB
+	version atomic.Int64 // bumped after every write to accounts or model quotas
 }
 
 // NewStorage creates a new storage instance
@@ -35,6 +38,21 @@ func NewStorage(dbPath string) (*Storage, error) {
 	return s, nil
 }
 
+// Version changes whenever account rows or model quotas are written, so
+// callers can tell when a cached snapshot is stale. last_used_at updates do
+// not change it.
+func (s *Storage) Version() int64 {
+	return s.version.Load()
+}
+
+// changed marks cached snapshots stale after a successful write
+func (s *Storage) changed(err error) error {
+	if err == nil {
+		s.version.Add(1)
+	}
+	return err
+}
+
 // migrate creates necessary tables
 func (s *Storage) migrate() error {
 	query := `
@@ -67,11 +85,127 @@ func (s *Storage) migrate() error {
 		FOREIGN KEY (account_id) REFERENCES accounts(id)
 	);
 
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		actor TEXT,
+		client_ip TEXT,
+		action TEXT NOT NULL,
+		target_id TEXT,
+		diff TEXT,
+		status_code INTEGER,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS model_quotas (
+		account_id INTEGER NOT NULL,
+		model TEXT NOT NULL,
+		remaining_fraction REAL,
+		reset_at DATETIME,
+		fetched_at DATETIME,
+		PRIMARY KEY (account_id, model),
+		FOREIGN KEY (account_id) REFERENCES accounts(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS quota_snapshots (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		account_id INTEGER NOT NULL,
+		model TEXT NOT NULL,
+		remaining_fraction REAL,
+		reset_at DATETIME,
+		fetched_at DATETIME,
+		FOREIGN KEY (account_id) REFERENCES accounts(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS job_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		job TEXT NOT NULL,
+		triggered_by TEXT,
+		status TEXT,
+		total INTEGER DEFAULT 0,
+		succeeded INTEGER DEFAULT 0,
+		failed INTEGER DEFAULT 0,
+		errors TEXT,
+		started_at DATETIME,
+		finished_at DATETIME
+	);
+
+	CREATE TABLE IF NOT EXISTS rate_limits (
+		account_id INTEGER NOT NULL,
+		model TEXT NOT NULL DEFAULT '',
+		email TEXT,
+		kind TEXT,
+		limited_at DATETIME,
+		reset_at DATETIME NOT NULL,
+		fail_count INTEGER DEFAULT 0,
+		last_error TEXT,
+		PRIMARY KEY (account_id, model)
+	);
+
+	CREATE TABLE IF NOT EXISTS session_bindings (
+		session_id TEXT PRIMARY KEY,
+		account_id INTEGER NOT NULL,
+		bound_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL
+	);
+
 	CREATE INDEX IF NOT EXISTS idx_accounts_status ON accounts(status);
 	CREATE INDEX IF NOT EXISTS idx_request_logs_account ON request_logs(account_id);
 	CREATE INDEX IF NOT EXISTS idx_request_logs_created ON request_logs(created_at);
+	CREATE INDEX IF NOT EXISTS idx_quota_snapshots_account ON quota_snapshots(account_id, fetched_at);
+	CREATE INDEX IF NOT EXISTS idx_quota_snapshots_fetched ON quota_snapshots(fetched_at);
+	CREATE INDEX IF NOT EXISTS idx_job_runs_job ON job_runs(job, started_at);
+	CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(created_at);
 	`
-	_, err := s.db.Exec(query)
+	if _, err := s.db.Exec(query); err != nil {
+		return err
+	}
+
+	// Columns added after the initial schema
+	if err := s.addColumn("request_logs", "error", "TEXT"); err != nil {
+		return err
+	}
+	if err := s.addColumn("accounts", "tags", "TEXT"); err != nil {
+		return err
+	}
+	if err := s.addColumn("accounts", "weight", "INTEGER DEFAULT 100"); err != nil {
+		return err
+	}
+	if err := s.addColumn("accounts", "pacing", "TEXT"); err != nil {
+		return err
+	}
+	if err := s.addColumn("request_logs", "requested_model", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+	return s.addColumn("request_logs", "ttft_ms", "INTEGER DEFAULT 0")
+}
+
+// addColumn adds a column to an existing table if it is missing
+func (s *Storage) addColumn(table, column, definition string) error {
+	rows, err := s.db.Query("PRAGMA table_info(" + table + ")")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	rows.Close()
+
+	_, err = s.db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
 	return err
 }
 
@@ -80,7 +214,8 @@ func (s *Storage) List() ([]Account, error) {
 	rows, err := s.db.Query(`
 		SELECT id, name, email, refresh_token, access_token, token_expiry,
 		       status, account_type, created_at, updated_at, last_used_at,
-		       quota_used, quota_limit, quota_reset_at
+		       quota_used, quota_limit, quota_reset_at, COALESCE(tags, ''), COALESCE(weight, 100),
+		       COALESCE(pacing, '')
 		FROM accounts ORDER BY id
 	`)
 	if err != nil {
@@ -93,11 +228,12 @@ func (s *Storage) List() ([]Account, error) {
 		var a Account
 		var tokenExpiry, lastUsedAt, quotaResetAt sql.NullTime
 		var accessToken sql.NullString
+		var tags, pacing string
 
 		err := rows.Scan(
 			&a.ID, &a.Name, &a.Email, &a.RefreshToken, &accessToken, &tokenExpiry,
 			&a.Status, &a.AccountType, &a.CreatedAt, &a.UpdatedAt, &lastUsedAt,
-			&a.QuotaUsed, &a.QuotaLimit, &quotaResetAt,
+			&a.QuotaUsed, &a.QuotaLimit, &quotaResetAt, &tags, &a.Weight, &pacing,
 		)
 		if err != nil {
 			return nil, err
@@ -115,6 +251,8 @@ func (s *Storage) List() ([]Account, error) {
 		if quotaResetAt.Valid {
 			a.QuotaResetAt = quotaResetAt.Time
 		}
+		a.Tags = splitTags(tags)
+		a.Pacing = decodePacing(pacing)
 
 		accounts = append(accounts, a)
 	}
@@ -127,16 +265,18 @@ func (s *Storage) Get(id int64) (*Account, error) {
 	var a Account
 	var tokenExpiry, lastUsedAt, quotaResetAt sql.NullTime
 	var accessToken sql.NullString
+	var tags, pacing string
 
 	err := s.db.QueryRow(`
 		SELECT id, name, email, refresh_token, access_token, token_expiry,
 		       status, account_type, created_at, updated_at, last_used_at,
-		       quota_used, quota_limit, quota_reset_at
+		       quota_used, quota_limit, quota_reset_at, COALESCE(tags, ''), COALESCE(weight, 100),
+		       COALESCE(pacing, '')
 		FROM accounts WHERE id = ?
 	`, id).Scan(
 		&a.ID, &a.Name, &a.Email, &a.RefreshToken, &accessToken, &tokenExpiry,
 		&a.Status, &a.AccountType, &a.CreatedAt, &a.UpdatedAt, &lastUsedAt,
-		&a.QuotaUsed, &a.QuotaLimit, &quotaResetAt,
+		&a.QuotaUsed, &a.QuotaLimit, &quotaResetAt, &tags, &a.Weight, &pacing,
 	)
 	if err != nil {
 		return nil, err
@@ -154,6 +294,8 @@ func (s *Storage) Get(id int64) (*Account, error) {
 	if quotaResetAt.Valid {
 		a.QuotaResetAt = quotaResetAt.Time
 	}
+	a.Tags = splitTags(tags)
+	a.Pacing = decodePacing(pacing)
 
 	return &a, nil
 }
@@ -161,10 +303,11 @@ func (s *Storage) Get(id int64) (*Account, error) {
 // Create creates a new account
 func (s *Storage) Create(input AccountInput) (*Account, error) {
 	result, err := s.db.Exec(`
-		INSERT INTO accounts (name, email, refresh_token, account_type, status)
-		VALUES (?, ?, ?, ?, ?)
-	`, input.Name, input.Email, input.RefreshToken, input.AccountType, StatusUnknown)
-	if err != nil {
+		INSERT INTO accounts (name, email, refresh_token, account_type, status, tags, weight, pacing)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, input.Name, input.Email, input.RefreshToken, input.AccountType, StatusUnknown,
+		joinTags(input.Tags), ClampWeight(input.Weight), encodePacing(input.Pacing))
+	if err := s.changed(err); err != nil {
 		return nil, err
 	}
 
@@ -179,25 +322,111 @@ func (s *Storage) Update(id int64, input AccountInput) (*Account, error) {
 		SET name = ?, email = ?, refresh_token = ?, account_type = ?, updated_at = ?
 		WHERE id = ?
 	`, input.Name, input.Email, input.RefreshToken, input.AccountType, time.Now(), id)
-	if err != nil {
+	if err := s.changed(err); err != nil {
 		return nil, err
 	}
 
+	// Tags, weight and pacing are only replaced when given
+	if input.Tags != nil {
+		if err := s.UpdateTags(id, input.Tags); err != nil {
+			return nil, err
+		}
+	}
+	if input.Weight != 0 {
+		if err := s.UpdateWeight(id, input.Weight); err != nil {
+			return nil, err
+		}
+	}
+	if input.Pacing != nil {
+		if err := s.UpdatePacing(id, input.Pacing); err != nil {
+			return nil, err
+		}
+	}
+
 	return s.Get(id)
 }
 
+// UpdateTags replaces the tags (pool memberships) of an account
+func (s *Storage) UpdateTags(id int64, tags []string) error {
+	_, err := s.db.Exec(`
+		UPDATE accounts SET tags = ?, updated_at = ? WHERE id = ?
+	`, joinTags(tags), time.Now(), id)
+	return s.changed(err)
+}
+
+// UpdateWeight sets the scheduling weight of an account
+func (s *Storage) UpdateWeight(id int64, weight int) error {
+	_, err := s.db.Exec(`
+		UPDATE accounts SET weight = ?, updated_at = ? WHERE id = ?
+	`, ClampWeight(weight), time.Now(), id)
+	return s.changed(err)
+}
+
+// UpdatePacing sets the pacing policy of an account; nil or an empty policy
+// removes it
+func (s *Storage) UpdatePacing(id int64, policy *PacingPolicy) error {
+	_, err := s.db.Exec(`
+		UPDATE accounts SET pacing = ?, updated_at = ? WHERE id = ?
+	`, encodePacing(policy), time.Now(), id)
+	return s.changed(err)
+}
+
+// ClampWeight bounds a weight to [MinWeight, MaxWeight], treating 0 as unset
+func ClampWeight(weight int) int {
+	switch {
+	case weight == 0:
+		return DefaultWeight
+	case weight < MinWeight:
+		return MinWeight
+	case weight > MaxWeight:
+		return MaxWeight
+	}
+	return weight
+}
+
+// joinTags stores tags as a comma-separated list
+func joinTags(tags []string) string {
+	return strings.Join(NormalizeTags(tags), ",")
+}
+
+// splitTags parses a stored tag list
+func splitTags(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
+
 // Delete deletes an account
 func (s *Storage) Delete(id int64) error {
+	for _, table := range []string{"model_quotas", "quota_snapshots"} {
+		if _, err := s.db.Exec("DELETE FROM "+table+" WHERE account_id = ?", id); err != nil {
+			return err
+		}
+	}
 	_, err := s.db.Exec("DELETE FROM accounts WHERE id = ?", id)
-	return err
+	return s.changed(err)
 }
 
-// UpdateStatus updates account status
-func (s *Storage) UpdateStatus(id int64, status Status) error {
+// UpdateStatus updates account status and reports whether it was written. A
+// manually disabled account keeps its status until Enable is called.
+func (s *Storage) UpdateStatus(id int64, status Status) (bool, error) {
+	result, err := s.db.Exec(`
+		UPDATE accounts SET status = ?, updated_at = ? WHERE id = ? AND status != ?
+	`, status, time.Now(), id, StatusDisabled)
+	if err := s.changed(err); err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// Enable clears the disabled status, leaving the account unknown until checked
+func (s *Storage) Enable(id int64) error {
 	_, err := s.db.Exec(`
-		UPDATE accounts SET status = ?, updated_at = ? WHERE id = ?
-	`, status, time.Now(), id)
-	return err
+		UPDATE accounts SET status = ?, updated_at = ? WHERE id = ? AND status = ?
+	`, StatusUnknown, time.Now(), id, StatusDisabled)
+	return s.changed(err)
 }
 
 // UpdateToken updates access token
@@ -205,15 +434,29 @@ func (s *Storage) UpdateToken(id int64, accessToken string, expiry time.Time) er
 	_, err := s.db.Exec(`
 		UPDATE accounts SET access_token = ?, token_expiry = ?, updated_at = ? WHERE id = ?
 	`, accessToken, expiry, time.Now(), id)
-	return err
+	return s.changed(err)
 }
 
-// UpdateLastUsed updates last used time
-func (s *Storage) UpdateLastUsed(id int64) error {
-	_, err := s.db.Exec(`
-		UPDATE accounts SET last_used_at = ? WHERE id = ?
-	`, time.Now(), id)
-	return err
+// SaveLastUsed writes a batch of last used times in one transaction
+func (s *Storage) SaveLastUsed(times map[int64]time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("UPDATE accounts SET last_used_at = ? WHERE id = ?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for id, t := range times {
+		if _, err := stmt.Exec(t, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
 // UpdateQuota updates quota info
@@ -221,7 +464,284 @@ func (s *Storage) UpdateQuota(id int64, used, limit int64, resetAt time.Time) er
 	_, err := s.db.Exec(`
 		UPDATE accounts SET quota_used = ?, quota_limit = ?, quota_reset_at = ?, updated_at = ? WHERE id = ?
 	`, used, limit, resetAt, time.Now(), id)
-	return err
+	return s.changed(err)
+}
+
+// quotaSnapshotRetention is how long quota snapshots are kept
+const quotaSnapshotRetention = 30 * 24 * time.Hour
+
+// SaveModelQuotas replaces the stored per-model quota of an account and
+// appends it to the snapshot history
+func (s *Storage) SaveModelQuotas(accountID int64, quotas []ModelQuota) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM model_quotas WHERE account_id = ?", accountID); err != nil {
+		return err
+	}
+
+	for _, q := range quotas {
+		var resetAt sql.NullTime
+		if !q.ResetAt.IsZero() {
+			resetAt = sql.NullTime{Time: q.ResetAt, Valid: true}
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO model_quotas (account_id, model, remaining_fraction, reset_at, fetched_at)
+			VALUES (?, ?, ?, ?, ?)
+		`, accountID, q.Model, q.RemainingFraction, resetAt, q.FetchedAt); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO quota_snapshots (account_id, model, remaining_fraction, reset_at, fetched_at)
+			VALUES (?, ?, ?, ?, ?)
+		`, accountID, q.Model, q.RemainingFraction, resetAt, q.FetchedAt.UTC()); err != nil {
+			return err
+		}
+	}
+
+	// Keep the snapshot history bounded
+	if _, err := tx.Exec("DELETE FROM quota_snapshots WHERE fetched_at < ?",
+		time.Now().Add(-quotaSnapshotRetention).UTC()); err != nil {
+		return err
+	}
+
+	return s.changed(tx.Commit())
+}
+
+// GetModelQuotas returns the stored quota for a model, keyed by account ID
+func (s *Storage) GetModelQuotas(model string) (map[int64]ModelQuota, error) {
+	rows, err := s.db.Query(`
+		SELECT account_id, model, remaining_fraction, reset_at, fetched_at
+		FROM model_quotas WHERE model = ?
+	`, model)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	quotas := make(map[int64]ModelQuota)
+	for rows.Next() {
+		q, err := scanModelQuota(rows)
+		if err != nil {
+			return nil, err
+		}
+		quotas[q.AccountID] = q
+	}
+	return quotas, rows.Err()
+}
+
+// GetAccountQuotas returns the stored per-model quota of an account
+func (s *Storage) GetAccountQuotas(accountID int64) ([]ModelQuota, error) {
+	rows, err := s.db.Query(`
+		SELECT account_id, model, remaining_fraction, reset_at, fetched_at
+		FROM model_quotas WHERE account_id = ? ORDER BY model
+	`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var quotas []ModelQuota
+	for rows.Next() {
+		q, err := scanModelQuota(rows)
+		if err != nil {
+			return nil, err
+		}
+		quotas = append(quotas, q)
+	}
+	return quotas, rows.Err()
+}
+
+// GetAllModelQuotas returns the last fetched quota of every account and model
+func (s *Storage) GetAllModelQuotas() ([]ModelQuota, error) {
+	rows, err := s.db.Query(`
+		SELECT account_id, model, remaining_fraction, reset_at, fetched_at
+		FROM model_quotas ORDER BY model, account_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var quotas []ModelQuota
+	for rows.Next() {
+		q, err := scanModelQuota(rows)
+		if err != nil {
+			return nil, err
+		}
+		quotas = append(quotas, q)
+	}
+	return quotas, rows.Err()
+}
+
+// GetQuotaHistory returns the quota snapshots of an account fetched since a
+// time, oldest first, optionally for one model
+func (s *Storage) GetQuotaHistory(accountID int64, model string, since time.Time) ([]ModelQuota, error) {
+	query := `
+		SELECT account_id, model, remaining_fraction, reset_at, fetched_at
+		FROM quota_snapshots WHERE account_id = ? AND fetched_at >= ?`
+	args := []interface{}{accountID, since.UTC()}
+	if model != "" {
+		query += " AND model = ?"
+		args = append(args, model)
+	}
+	query += " ORDER BY fetched_at, model"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var quotas []ModelQuota
+	for rows.Next() {
+		q, err := scanModelQuota(rows)
+		if err != nil {
+			return nil, err
+		}
+		quotas = append(quotas, q)
+	}
+	return quotas, rows.Err()
+}
+
+// GetQuotaSnapshots returns the quota snapshots of all accounts fetched since
+// a time, grouped by account and model, oldest first
+func (s *Storage) GetQuotaSnapshots(since time.Time) ([]ModelQuota, error) {
+	rows, err := s.db.Query(`
+		SELECT account_id, model, remaining_fraction, reset_at, fetched_at
+		FROM quota_snapshots WHERE fetched_at >= ?
+		ORDER BY account_id, model, fetched_at
+	`, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var quotas []ModelQuota
+	for rows.Next() {
+		q, err := scanModelQuota(rows)
+		if err != nil {
+			return nil, err
+		}
+		quotas = append(quotas, q)
+	}
+	return quotas, rows.Err()
+}
+
+func scanModelQuota(rows *sql.Rows) (ModelQuota, error) {
+	var q ModelQuota
+	var resetAt, fetchedAt sql.NullTime
+	if err := rows.Scan(&q.AccountID, &q.Model, &q.RemainingFraction, &resetAt, &fetchedAt); err != nil {
+		return q, err
+	}
+	if resetAt.Valid {
+		q.ResetAt = resetAt.Time
+	}
+	if fetchedAt.Valid {
+		q.FetchedAt = fetchedAt.Time
+	}
+	q.Percentage = int(q.RemainingFraction * 100)
+	return q, nil
+}
+
+// SaveRuntimeState writes a batch of rate limit and session binding changes
+// in one transaction. Times are stored in UTC so they compare as text.
+func (s *Storage) SaveRuntimeState(limits, removedLimits []RateLimitEntry, bindings []SessionBinding, removedBindings []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, e := range limits {
+		if _, err := tx.Exec(`
+			INSERT OR REPLACE INTO rate_limits
+				(account_id, model, email, kind, limited_at, reset_at, fail_count, last_error)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, e.AccountID, e.Model, e.Email, string(e.Kind), e.LimitedAt.UTC(), e.ResetAt.UTC(), e.FailCount, e.LastError); err != nil {
+			return err
+		}
+	}
+	for _, e := range removedLimits {
+		if _, err := tx.Exec("DELETE FROM rate_limits WHERE account_id = ? AND model = ?", e.AccountID, e.Model); err != nil {
+			return err
+		}
+	}
+	for _, b := range bindings {
+		if _, err := tx.Exec(`
+			INSERT OR REPLACE INTO session_bindings (session_id, account_id, bound_at, expires_at)
+			VALUES (?, ?, ?, ?)
+		`, b.SessionID, b.AccountID, b.BoundAt.UTC(), b.ExpiresAt.UTC()); err != nil {
+			return err
+		}
+	}
+	for _, id := range removedBindings {
+		if _, err := tx.Exec("DELETE FROM session_bindings WHERE session_id = ?", id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// LoadRateLimits deletes expired rate limits and returns the rest
+func (s *Storage) LoadRateLimits(now time.Time) ([]RateLimitEntry, error) {
+	if _, err := s.db.Exec("DELETE FROM rate_limits WHERE reset_at <= ?", now.UTC()); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT account_id, model, email, kind, limited_at, reset_at, fail_count, last_error
+		FROM rate_limits
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []RateLimitEntry
+	for rows.Next() {
+		var e RateLimitEntry
+		var email, kind, lastError sql.NullString
+		var limitedAt sql.NullTime
+		if err := rows.Scan(&e.AccountID, &e.Model, &email, &kind, &limitedAt, &e.ResetAt, &e.FailCount, &lastError); err != nil {
+			return nil, err
+		}
+		e.Email = email.String
+		e.Kind = LimitKind(kind.String)
+		e.LastError = lastError.String
+		if limitedAt.Valid {
+			e.LimitedAt = limitedAt.Time
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// LoadSessionBindings deletes expired session bindings and returns the rest
+func (s *Storage) LoadSessionBindings(now time.Time) ([]SessionBinding, error) {
+	if _, err := s.db.Exec("DELETE FROM session_bindings WHERE expires_at <= ?", now.UTC()); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query("SELECT session_id, account_id, bound_at, expires_at FROM session_bindings")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bindings []SessionBinding
+	for rows.Next() {
+		var b SessionBinding
+		if err := rows.Scan(&b.SessionID, &b.AccountID, &b.BoundAt, &b.ExpiresAt); err != nil {
+			return nil, err
+		}
+		bindings = append(bindings, b)
+	}
+	return bindings, rows.Err()
 }
 
 // UpdateAccountType updates account subscription type
@@ -229,7 +749,7 @@ func (s *Storage) UpdateAccountType(id int64, accountType string) error {
 	_, err := s.db.Exec(`
 		UPDATE accounts SET account_type = ?, updated_at = ? WHERE id = ?
 	`, accountType, time.Now(), id)
-	return err
+	return s.changed(err)
 }
 
 // GetActiveAccounts returns accounts with active status
@@ -240,7 +760,8 @@ func (s *Storage) GetActiveAccounts() ([]Account, error) {
 	rows, err := s.db.Query(`
 		SELECT id, name, email, refresh_token, access_token, token_expiry,
 		       status, account_type, created_at, updated_at, last_used_at,
-		       quota_used, quota_limit, quota_reset_at
+		       quota_used, quota_limit, quota_reset_at, COALESCE(tags, ''), COALESCE(weight, 100),
+		       COALESCE(pacing, '')
 		FROM accounts 
 		WHERE status = ?
 		ORDER BY 
@@ -263,11 +784,12 @@ func (s *Storage) GetActiveAccounts() ([]Account, error) {
 		var a Account
 		var tokenExpiry, lastUsedAt, quotaResetAt sql.NullTime
 		var accessToken sql.NullString
+		var tags, pacing string
 
 		err := rows.Scan(
 			&a.ID, &a.Name, &a.Email, &a.RefreshToken, &accessToken, &tokenExpiry,
 			&a.Status, &a.AccountType, &a.CreatedAt, &a.UpdatedAt, &lastUsedAt,
-			&a.QuotaUsed, &a.QuotaLimit, &quotaResetAt,
+			&a.QuotaUsed, &a.QuotaLimit, &quotaResetAt, &tags, &a.Weight, &pacing,
 		)
 		if err != nil {
 			return nil, err
@@ -285,6 +807,8 @@ func (s *Storage) GetActiveAccounts() ([]Account, error) {
 		if quotaResetAt.Valid {
 			a.QuotaResetAt = quotaResetAt.Time
 		}
+		a.Tags = splitTags(tags)
+		a.Pacing = decodePacing(pacing)
 
 		accounts = append(accounts, a)
 	}
@@ -292,12 +816,102 @@ func (s *Storage) GetActiveAccounts() ([]Account, error) {
 	return accounts, nil
 }
 
-// LogRequest logs a request
-func (s *Storage) LogRequest(accountID int64, model string, tokensIn, tokensOut, latencyMs, statusCode int) error {
+// LoadRequestTimes returns when each account served requests since a time,
+// oldest first
+func (s *Storage) LoadRequestTimes(since time.Time) (map[int64][]time.Time, error) {
+	rows, err := s.db.Query(`
+		SELECT account_id, created_at FROM request_logs
+		WHERE created_at >= ? ORDER BY created_at
+	`, since.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	times := make(map[int64][]time.Time)
+	for rows.Next() {
+		var id int64
+		var t time.Time
+		if err := rows.Scan(&id, &t); err != nil {
+			return nil, err
+		}
+		times[id] = append(times[id], t)
+	}
+	return times, rows.Err()
+}
+
+// CountRequests returns how many successful requests each account served
+// per model since a time
+func (s *Storage) CountRequests(since time.Time) (map[int64]map[string]int, error) {
+	rows, err := s.db.Query(`
+		SELECT account_id, model, COUNT(*) FROM request_logs
+		WHERE created_at >= ? AND status_code = 200
+		GROUP BY account_id, model
+	`, since.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int64]map[string]int)
+	for rows.Next() {
+		var id int64
+		var model string
+		var n int
+		if err := rows.Scan(&id, &model, &n); err != nil {
+			return nil, err
+		}
+		if counts[id] == nil {
+			counts[id] = make(map[string]int)
+		}
+		counts[id][model] = n
+	}
+	return counts, rows.Err()
+}
+
+// LoadTokenUsage returns the tokens of each request that used any since a
+// time, oldest first
+func (s *Storage) LoadTokenUsage(since time.Time) ([]UsageRecord, error) {
+	rows, err := s.db.Query(`
+		SELECT account_id, model, tokens_in + tokens_out, created_at FROM request_logs
+		WHERE created_at >= ? AND tokens_in + tokens_out > 0 ORDER BY created_at
+	`, since.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []UsageRecord
+	for rows.Next() {
+		var r UsageRecord
+		if err := rows.Scan(&r.AccountID, &r.Model, &r.Tokens, &r.At); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// CountOutcomes returns how many requests were logged since a time and how
+// many of them failed
+func (s *Storage) CountOutcomes(since time.Time) (total, failed int, err error) {
+	err = s.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN status_code != 200 THEN 1 ELSE 0 END), 0)
+		FROM request_logs WHERE created_at >= ?
+	`, since.UTC().Format("2006-01-02 15:04:05")).Scan(&total, &failed)
+	return total, failed, err
+}
+
+// LogRequest logs a request. Failed requests keep the full, unredacted
+// upstream error, which is only exposed to admins through the request log.
+func (s *Storage) LogRequest(entry RequestLog) error {
+	errMsg := sql.NullString{String: entry.Error, Valid: entry.Error != ""}
 	_, err := s.db.Exec(`
-		INSERT INTO request_logs (account_id, model, tokens_in, tokens_out, latency_ms, status_code)
-		VALUES (?, ?, ?, ?, ?, ?)
-	`, accountID, model, tokensIn, tokensOut, latencyMs, statusCode)
+		INSERT INTO request_logs
+			(account_id, model, requested_model, tokens_in, tokens_out, latency_ms, ttft_ms, status_code, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, entry.AccountID, entry.Model, entry.RequestedModel, entry.TokensIn, entry.TokensOut,
+		entry.LatencyMs, entry.TTFTMs, entry.StatusCode, errMsg)
 	return err
 }
 
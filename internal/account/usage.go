@@ -0,0 +1,214 @@
+package account
+
+import (
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"antigravity-lite/config"
+)
+
+// usageWindow is the span of the rolling token budget
+const usageWindow = 5 * time.Hour
+
+// TokenUsage is the tokens an account used on one model in each budget window
+type TokenUsage struct {
+	Model           string    `json:"model"`
+	Daily           int64     `json:"daily"`
+	Rolling5h       int64     `json:"rolling_5h"`
+	DailyBudget     int64     `json:"daily_budget,omitempty"`
+	Rolling5hBudget int64     `json:"rolling_5h_budget,omitempty"`
+	ExhaustedUntil  time.Time `json:"exhausted_until"` // zero when within budget
+}
+
+// UsageRecord is the tokens one logged request used
+type UsageRecord struct {
+	AccountID int64
+	Model     string
+	Tokens    int64
+	At        time.Time
+}
+
+type usageKey struct {
+	accountID int64
+	model     string
+}
+
+// usageEntry is one request's tokens
+type usageEntry struct {
+	at     time.Time
+	tokens int64
+}
+
+// UsageTracker keeps the last day of token usage per account and model so
+// budgets can be checked without querying the request log
+type UsageTracker struct {
+	mu      sync.Mutex
+	entries map[usageKey][]usageEntry // oldest first
+}
+
+// NewUsageTracker creates an empty tracker
+func NewUsageTracker() *UsageTracker {
+	return &UsageTracker{
+		entries: make(map[usageKey][]usageEntry),
+	}
+}
+
+// Add records tokens used by an account on a model
+func (t *UsageTracker) Add(accountID int64, model string, tokens int64, now time.Time) {
+	if tokens <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := usageKey{accountID, model}
+	t.entries[key] = append(pruneUsage(t.entries[key], now), usageEntry{at: now, tokens: tokens})
+}
+
+// Wait returns how long the budget keeps the account from serving the model
+func (t *UsageTracker) Wait(accountID int64, model string, budget *config.TokenBudgetRule, now time.Time) time.Duration {
+	if budget == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := usageKey{accountID, model}
+	entries := pruneUsage(t.entries[key], now)
+	t.entries[key] = entries
+	return budgetWait(entries, budget, now)
+}
+
+// Usage returns the usage of every model an account used within the last
+// day, with the budget of each
+func (t *UsageTracker) Usage(accountID int64, budgetFor func(model string) *config.TokenBudgetRule, now time.Time) []TokenUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var usage []TokenUsage
+	for key, entries := range t.entries {
+		if key.accountID != accountID {
+			continue
+		}
+		entries = pruneUsage(entries, now)
+		t.entries[key] = entries
+		if len(entries) == 0 {
+			continue
+		}
+
+		u := TokenUsage{
+			Model:     key.model,
+			Daily:     sumSince(entries, startOfDay(now)),
+			Rolling5h: sumSince(entries, now.Add(-usageWindow)),
+		}
+		if budget := budgetFor(key.model); budget != nil {
+			u.DailyBudget = budget.Daily
+			u.Rolling5hBudget = budget.Rolling5h
+			if wait := budgetWait(entries, budget, now); wait > 0 {
+				u.ExhaustedUntil = now.Add(wait)
+			}
+		}
+		usage = append(usage, u)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Model < usage[j].Model })
+	return usage
+}
+
+// Reset forgets the usage of an account
+func (t *UsageTracker) Reset(accountID int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key := range t.entries {
+		if key.accountID == accountID {
+			delete(t.entries, key)
+		}
+	}
+}
+
+// restore seeds usage, e.g. from request logs after a restart
+func (t *UsageTracker) restore(records []UsageRecord) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, r := range records {
+		key := usageKey{r.AccountID, r.Model}
+		t.entries[key] = append(t.entries[key], usageEntry{at: r.At, tokens: r.Tokens})
+	}
+}
+
+// budgetWait returns how long until usage is back under both limits: the
+// next midnight for the daily budget, or until enough of the rolling window
+// has aged out
+func budgetWait(entries []usageEntry, budget *config.TokenBudgetRule, now time.Time) time.Duration {
+	var wait time.Duration
+	if budget.Daily > 0 && sumSince(entries, startOfDay(now)) >= budget.Daily {
+		wait = startOfDay(now).AddDate(0, 0, 1).Sub(now)
+	}
+	if budget.Rolling5h > 0 {
+		cutoff := now.Add(-usageWindow)
+		used := sumSince(entries, cutoff)
+		for _, e := range entries {
+			if used < budget.Rolling5h {
+				break
+			}
+			if e.at.Before(cutoff) {
+				continue
+			}
+			used -= e.tokens
+			if w := e.at.Add(usageWindow).Sub(now); w > wait {
+				wait = w
+			}
+		}
+	}
+	return wait
+}
+
+// matchBudget returns the first rule matching an account and model
+func matchBudget(rules []config.TokenBudgetRule, acc Account, model string) *config.TokenBudgetRule {
+	for i := range rules {
+		r := &rules[i]
+		if r.Daily <= 0 && r.Rolling5h <= 0 {
+			continue
+		}
+		if r.Model != "" {
+			if ok, _ := path.Match(r.Model, model); !ok {
+				continue
+			}
+		}
+		if r.AccountType != "" && r.AccountType != acc.AccountType {
+			continue
+		}
+		if r.Email != "" && r.Email != acc.Email {
+			continue
+		}
+		return r
+	}
+	return nil
+}
+
+// pruneUsage drops entries older than a day, which covers both windows
+func pruneUsage(entries []usageEntry, now time.Time) []usageEntry {
+	cutoff := now.Add(-24 * time.Hour)
+	i := 0
+	for i < len(entries) && !entries[i].at.After(cutoff) {
+		i++
+	}
+	if i > 0 {
+		entries = append(entries[:0], entries[i:]...)
+	}
+	return entries
+}
+
+func sumSince(entries []usageEntry, since time.Time) int64 {
+	var sum int64
+	for i := len(entries) - 1; i >= 0 && !entries[i].at.Before(since); i-- {
+		sum += entries[i].tokens
+	}
+	return sum
+}
+
+// startOfDay returns midnight of the day containing t, in t's location
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,466 @@
+package alerts
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"antigravity-lite/config"
+	"antigravity-lite/internal/account"
+)
+
+// Rule names, used in webhook rule filters and alert keys
+const (
+	RuleStatusChange = "status_change"
+	RuleNoAccounts   = "no_accounts"
+	RuleQuotaLow     = "quota_low"
+	RuleErrorRate    = "error_rate"
+	RuleTest         = "test"
+)
+
+// Severity values
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// checkInterval is how often the pool conditions are evaluated
+const checkInterval = time.Minute
+
+// maxHistory caps the alerts kept for the API
+const maxHistory = 100
+
+// Alert is one notification. Key identifies the condition for
+// de-duplication; a resolved alert reports that the condition cleared.
+type Alert struct {
+	Key      string    `json:"key"`
+	Rule     string    `json:"rule"`
+	Severity string    `json:"severity"`
+	Title    string    `json:"title"`
+	Message  string    `json:"message"`
+	Resolved bool      `json:"resolved"`
+	Time     time.Time `json:"time"`
+}
+
+// Alerter raises alerts from account events and periodic pool checks and
+// delivers them to the configured webhooks
+type Alerter struct {
+	mgr      *account.Manager
+	cfg      atomic.Pointer[config.AlertsConfig] // private copy, see SetConfig
+	mu       sync.Mutex
+	firing   map[string]Alert              // conditions currently alerting, by key
+	held     map[string]bool               // firing conditions not delivered yet because of the cooldown
+	lastSent map[string]time.Time          // last delivery per key, kept across resolves for the cooldown
+	empty    map[string]account.PoolFilter // pools without active accounts, by key
+	history  []Alert                       // newest last
+	queue    chan Alert
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewAlerter creates an alerter and subscribes it to the manager's events.
+// Call Start to begin the periodic checks and deliveries.
+func NewAlerter(mgr *account.Manager, cfg *config.Config) *Alerter {
+	a := &Alerter{
+		mgr:      mgr,
+		firing:   make(map[string]Alert),
+		held:     make(map[string]bool),
+		lastSent: make(map[string]time.Time),
+		empty:    make(map[string]account.PoolFilter),
+		queue:    make(chan Alert, 64),
+		stop:     make(chan struct{}),
+	}
+	a.SetConfig(cfg.Alerts)
+	mgr.OnEvent(a.handleEvent)
+	return a
+}
+
+// SetConfig replaces the alerting settings. The alerter keeps its own copy,
+// so the caller may go on changing cfg.
+func (a *Alerter) SetConfig(cfg config.AlertsConfig) {
+	cfg.Rules.StatusChange = append([]string(nil), cfg.Rules.StatusChange...)
+	webhooks := make([]config.WebhookConfig, len(cfg.Webhooks))
+	for i, hook := range cfg.Webhooks {
+		hook.Rules = append([]string(nil), hook.Rules...)
+		webhooks[i] = hook
+	}
+	cfg.Webhooks = webhooks
+	a.cfg.Store(&cfg)
+}
+
+func (a *Alerter) config() *config.AlertsConfig {
+	return a.cfg.Load()
+}
+
+// Start launches the check loop and the webhook sender
+func (a *Alerter) Start() {
+	a.wg.Add(2)
+	go a.checkLoop()
+	go a.sendLoop()
+}
+
+// Stop ends the check loop and waits for queued alerts to be sent
+func (a *Alerter) Stop() {
+	close(a.stop)
+	a.wg.Wait()
+}
+
+// Firing returns the conditions currently alerting
+func (a *Alerter) Firing() []Alert {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	firing := make([]Alert, 0, len(a.firing))
+	for _, alert := range a.firing {
+		firing = append(firing, alert)
+	}
+	return firing
+}
+
+// History returns the most recent alerts, newest first
+func (a *Alerter) History() []Alert {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	history := make([]Alert, len(a.history))
+	for i, alert := range a.history {
+		history[len(a.history)-1-i] = alert
+	}
+	return history
+}
+
+// Test sends a test alert to every webhook, bypassing rules and cooldowns
+func (a *Alerter) Test() []error {
+	alert := Alert{
+		Key:      RuleTest,
+		Rule:     RuleTest,
+		Severity: SeverityInfo,
+		Title:    "Test alert",
+		Message:  "Webhook delivery from antigravity-lite is working",
+		Time:     time.Now(),
+	}
+	a.record(alert)
+
+	var errs []error
+	for _, hook := range a.config().Webhooks {
+		if err := deliver(hook, alert); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", hook.Name, err))
+		}
+	}
+	return errs
+}
+
+// handleEvent turns account events into alerts. It runs on the request path,
+// so it only queues.
+func (a *Alerter) handleEvent(e account.Event) {
+	rules := a.config().Rules
+	switch e.Kind {
+	case account.EventStatusChange:
+		key := fmt.Sprintf("%s:%d", RuleStatusChange, e.Account.ID)
+		if !contains(rules.StatusChange, string(e.Status)) {
+			a.resolve(key, fmt.Sprintf("Account %s is %s again", e.Account.Email, e.Status))
+			return
+		}
+		a.fire(Alert{
+			Key:      key,
+			Rule:     RuleStatusChange,
+			Severity: SeverityWarning,
+			Title:    fmt.Sprintf("Account %s is %s", e.Account.Email, e.Status),
+			Message:  fmt.Sprintf("Account #%d (%s) changed from %s to %s: %s", e.Account.ID, e.Account.Email, e.Previous, e.Status, e.Reason),
+		})
+	case account.EventNoAccounts:
+		if !rules.NoAccounts {
+			return
+		}
+		a.noAccounts(e.Pools, "Requests are failing because no account can serve them")
+	}
+}
+
+// noAccounts alerts that no active account is left in the pools, or at all
+// when pools is empty
+func (a *Alerter) noAccounts(pools account.PoolFilter, message string) {
+	key := RuleNoAccounts + ":" + pools.String()
+	title := "No active accounts available"
+	if len(pools) > 0 {
+		title = fmt.Sprintf("No active accounts in pool %s", pools)
+	}
+
+	a.mu.Lock()
+	a.empty[key] = pools
+	a.mu.Unlock()
+
+	a.fire(Alert{
+		Key:      key,
+		Rule:     RuleNoAccounts,
+		Severity: SeverityCritical,
+		Title:    title,
+		Message:  message,
+	})
+}
+
+func (a *Alerter) checkLoop() {
+	defer a.wg.Done()
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+			if a.config().Enabled {
+				a.check()
+				a.sendHeld()
+			}
+		}
+	}
+}
+
+// check evaluates the pool-wide rules, firing or resolving each condition
+func (a *Alerter) check() {
+	rules := a.config().Rules
+
+	if rules.NoAccounts {
+		a.checkNoAccounts()
+	}
+	if rules.QuotaBelow > 0 {
+		a.checkQuota(rules.QuotaBelow)
+	}
+	if rules.ErrorRate > 0 && rules.ErrorWindow > 0 {
+		a.checkErrorRate(rules)
+	}
+}
+
+// checkNoAccounts alerts when no account is active and resolves pools that
+// have active accounts again
+func (a *Alerter) checkNoAccounts() {
+	accounts, err := a.mgr.List()
+	if err != nil {
+		log.Printf("Alert check failed to list accounts: %v", err)
+		return
+	}
+	var active []account.Account
+	for _, acc := range accounts {
+		if acc.Status == account.StatusActive {
+			active = append(active, acc)
+		}
+	}
+	if len(active) == 0 {
+		a.noAccounts(nil, fmt.Sprintf("None of the %d accounts is active", len(accounts)))
+		return
+	}
+
+	a.mu.Lock()
+	empty := make(map[string]account.PoolFilter, len(a.empty))
+	for key, pools := range a.empty {
+		empty[key] = pools
+	}
+	a.mu.Unlock()
+
+	for key, pools := range empty {
+		for _, acc := range active {
+			if pools.Allows(acc.Tags) {
+				a.mu.Lock()
+				delete(a.empty, key)
+				a.mu.Unlock()
+				a.resolve(key, "Active accounts are available again")
+				break
+			}
+		}
+	}
+}
+
+func (a *Alerter) checkQuota(threshold int) {
+	summary, err := a.mgr.QuotaSummary(nil)
+	if err != nil {
+		log.Printf("Alert check failed to load quota: %v", err)
+		return
+	}
+	for _, sum := range summary {
+		key := fmt.Sprintf("%s:%s", RuleQuotaLow, sum.Model)
+		percent := int(sum.AvgRemaining * 100)
+		if percent >= threshold {
+			a.resolve(key, fmt.Sprintf("Quota for %s is back to %d%%", sum.Model, percent))
+			continue
+		}
+		message := fmt.Sprintf("%d%% of %s quota is left across %d accounts (%d exhausted)", percent, sum.Model, sum.Accounts, sum.Exhausted)
+		if !sum.NextResetAt.IsZero() {
+			message += fmt.Sprintf(", next reset at %s", sum.NextResetAt.Local().Format("15:04"))
+		}
+		a.fire(Alert{
+			Key:      key,
+			Rule:     RuleQuotaLow,
+			Severity: SeverityWarning,
+			Title:    fmt.Sprintf("Quota for %s below %d%%", sum.Model, threshold),
+			Message:  message,
+		})
+	}
+}
+
+func (a *Alerter) checkErrorRate(rules config.AlertRules) {
+	window := time.Duration(rules.ErrorWindow) * time.Minute
+	total, failed, err := a.mgr.GetStorage().CountOutcomes(time.Now().Add(-window))
+	if err != nil {
+		log.Printf("Alert check failed to count requests: %v", err)
+		return
+	}
+	if total == 0 || total < rules.MinRequests {
+		return
+	}
+
+	percent := failed * 100 / total
+	if percent < rules.ErrorRate {
+		a.resolve(RuleErrorRate, fmt.Sprintf("Error rate is back to %d%%", percent))
+		return
+	}
+	a.fire(Alert{
+		Key:      RuleErrorRate,
+		Rule:     RuleErrorRate,
+		Severity: SeverityCritical,
+		Title:    fmt.Sprintf("Error rate at %d%%", percent),
+		Message:  fmt.Sprintf("%d of %d requests failed in the last %d minutes", failed, total, rules.ErrorWindow),
+	})
+}
+
+// fire raises an alert unless the same condition was delivered within the
+// cooldown, including by an earlier episode that has since resolved, so a
+// flapping condition alerts at most once per cooldown. A held-back alert
+// stays firing and is sent by sendHeld once the cooldown has passed.
+func (a *Alerter) fire(alert Alert) {
+	cfg := a.config()
+	if !cfg.Enabled {
+		return
+	}
+	alert.Time = time.Now()
+	cooldown := time.Duration(cfg.Cooldown) * time.Minute
+
+	a.mu.Lock()
+	_, firing := a.firing[alert.Key]
+	if alert.Time.Sub(a.lastSent[alert.Key]) < cooldown {
+		if !firing || a.held[alert.Key] {
+			a.firing[alert.Key] = alert
+			a.held[alert.Key] = true
+		}
+		a.mu.Unlock()
+		return
+	}
+	a.firing[alert.Key] = alert
+	a.lastSent[alert.Key] = alert.Time
+	delete(a.held, alert.Key)
+	a.mu.Unlock()
+
+	a.record(alert)
+	a.enqueue(alert)
+}
+
+// sendHeld delivers the alerts held back by the cooldown whose conditions
+// are still firing once the cooldown has passed
+func (a *Alerter) sendHeld() {
+	now := time.Now()
+	cooldown := time.Duration(a.config().Cooldown) * time.Minute
+
+	var due []Alert
+	a.mu.Lock()
+	for key := range a.held {
+		if now.Sub(a.lastSent[key]) < cooldown {
+			continue
+		}
+		alert := a.firing[key]
+		alert.Time = now
+		a.firing[key] = alert
+		a.lastSent[key] = now
+		delete(a.held, key)
+		due = append(due, alert)
+	}
+	a.mu.Unlock()
+
+	for _, alert := range due {
+		a.record(alert)
+		a.enqueue(alert)
+	}
+}
+
+// resolve reports that a firing condition cleared; it does nothing for
+// conditions that are not firing or were never delivered
+func (a *Alerter) resolve(key, message string) {
+	a.mu.Lock()
+	alert, firing := a.firing[key]
+	if !firing {
+		a.mu.Unlock()
+		return
+	}
+	delete(a.firing, key)
+	if a.held[key] {
+		delete(a.held, key)
+		a.mu.Unlock()
+		return
+	}
+	a.mu.Unlock()
+
+	alert.Resolved = true
+	alert.Severity = SeverityInfo
+	alert.Title = "Resolved: " + alert.Title
+	alert.Message = message
+	alert.Time = time.Now()
+
+	a.record(alert)
+	a.enqueue(alert)
+}
+
+func (a *Alerter) record(alert Alert) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.history = append(a.history, alert)
+	if len(a.history) > maxHistory {
+		a.history = append(a.history[:0], a.history[len(a.history)-maxHistory:]...)
+	}
+}
+
+// enqueue hands an alert to the sender without blocking
+func (a *Alerter) enqueue(alert Alert) {
+	select {
+	case a.queue <- alert:
+	default:
+		log.Printf("Alert queue full, dropping %q", alert.Title)
+	}
+}
+
+// sendLoop delivers queued alerts to the webhooks subscribed to their rule
+func (a *Alerter) sendLoop() {
+	defer a.wg.Done()
+	for {
+		select {
+		case alert := <-a.queue:
+			a.send(alert)
+		case <-a.stop:
+			for {
+				select {
+				case alert := <-a.queue:
+					a.send(alert)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (a *Alerter) send(alert Alert) {
+	for _, hook := range a.config().Webhooks {
+		if len(hook.Rules) > 0 && !contains(hook.Rules, alert.Rule) {
+			continue
+		}
+		if err := deliver(hook, alert); err != nil {
+			log.Printf("Failed to send alert to webhook %s: %v", hook.Name, err)
+		}
+	}
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
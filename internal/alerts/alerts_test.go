@@ -0,0 +1,112 @@
+package alerts
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"antigravity-lite/config"
+	"antigravity-lite/internal/account"
+)
+
+func newTestAlerter(cooldown int) *Alerter {
+	a := &Alerter{
+		firing:   make(map[string]Alert),
+		held:     make(map[string]bool),
+		lastSent: make(map[string]time.Time),
+		empty:    make(map[string]account.PoolFilter),
+		queue:    make(chan Alert, 64),
+	}
+	a.SetConfig(config.AlertsConfig{Enabled: true, Cooldown: cooldown})
+	return a
+}
+
+// queued drains the delivery queue
+func queued(a *Alerter) []Alert {
+	var alerts []Alert
+	for {
+		select {
+		case alert := <-a.queue:
+			alerts = append(alerts, alert)
+		default:
+			return alerts
+		}
+	}
+}
+
+func TestFlappingConditionRespectsCooldown(t *testing.T) {
+	a := newTestAlerter(30)
+	alert := Alert{Key: "status_change:1", Rule: RuleStatusChange, Title: "Account a is expired"}
+
+	steps := []struct {
+		name         string
+		action       func()
+		wantSent     int
+		wantResolved bool
+		wantFiring   bool
+	}{
+		{"first fire is sent", func() { a.fire(alert) }, 1, false, true},
+		{"repeat within cooldown is deduplicated", func() { a.fire(alert) }, 0, false, true},
+		{"resolve is sent", func() { a.resolve(alert.Key, "active again") }, 1, true, false},
+		{"re-fire within cooldown is held", func() { a.fire(alert) }, 0, false, true},
+		{"held alert is not sent before the cooldown", a.sendHeld, 0, false, true},
+		{"resolving a held alert sends nothing", func() { a.resolve(alert.Key, "active again") }, 0, false, false},
+		{"fire again is held", func() { a.fire(alert) }, 0, false, true},
+		{"held alert is sent after the cooldown", func() {
+			a.lastSent[alert.Key] = time.Now().Add(-31 * time.Minute)
+			a.sendHeld()
+		}, 1, false, true},
+		{"sent held alert resolves", func() { a.resolve(alert.Key, "active again") }, 1, true, false},
+	}
+
+	for _, step := range steps {
+		step.action()
+		sent := queued(a)
+		if len(sent) != step.wantSent {
+			t.Fatalf("%s: sent %d alerts, want %d: %+v", step.name, len(sent), step.wantSent, sent)
+		}
+		if len(sent) == 1 && sent[0].Resolved != step.wantResolved {
+			t.Errorf("%s: resolved = %v, want %v", step.name, sent[0].Resolved, step.wantResolved)
+		}
+		if firing := len(a.Firing()) == 1; firing != step.wantFiring {
+			t.Errorf("%s: firing = %v, want %v", step.name, firing, step.wantFiring)
+		}
+	}
+}
+
+func TestSetConfigCopies(t *testing.T) {
+	cfg := config.AlertsConfig{
+		Rules:    config.AlertRules{StatusChange: []string{"expired"}},
+		Webhooks: []config.WebhookConfig{{Name: "ops", URL: "http://a", Rules: []string{RuleErrorRate}}},
+	}
+	a := newTestAlerter(0)
+	a.SetConfig(cfg)
+
+	cfg.Rules.StatusChange[0] = "banned"
+	cfg.Webhooks[0].URL = "http://b"
+	cfg.Webhooks[0].Rules[0] = RuleQuotaLow
+
+	got := a.config()
+	if got.Rules.StatusChange[0] != "expired" || got.Webhooks[0].URL != "http://a" || got.Webhooks[0].Rules[0] != RuleErrorRate {
+		t.Errorf("alerter config changed with the caller's: %+v", got)
+	}
+}
+
+func TestDeliveryErrorHidesWebhookURL(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	hookURL := srv.URL + "/services/T000/B000/secret-token"
+	srv.Close()
+
+	a := newTestAlerter(0)
+	a.SetConfig(config.AlertsConfig{Webhooks: []config.WebhookConfig{{Name: "ops", URL: hookURL}}})
+
+	errs := a.Test()
+	if len(errs) != 1 {
+		t.Fatalf("Test() = %v, want one delivery error", errs)
+	}
+	if msg := errs[0].Error(); strings.Contains(msg, "secret-token") {
+		t.Errorf("error %q contains the webhook URL", msg)
+	}
+}
@@ -0,0 +1,91 @@
+package alerts
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"antigravity-lite/config"
+)
+
+// Webhook payload formats
+const (
+	FormatJSON    = "json"
+	FormatSlack   = "slack"
+	FormatDiscord = "discord"
+)
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// discordColors maps severities to embed colors
+var discordColors = map[string]int{
+	SeverityInfo:     0x2ecc71,
+	SeverityWarning:  0xf1c40f,
+	SeverityCritical: 0xe74c3c,
+}
+
+// deliver posts an alert to a webhook in its format
+func deliver(hook config.WebhookConfig, alert Alert) error {
+	body, err := json.Marshal(payload(hook.Format, alert))
+	if err != nil {
+		return err
+	}
+
+	resp, err := webhookClient.Post(hook.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// Slack and Discord webhook URLs are credentials; drop the URL the
+		// client error carries before it reaches logs or API responses
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("%s: %w", urlErr.Op, urlErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// payload builds the request body for a webhook format; unknown formats get
+// the generic JSON body
+func payload(format string, alert Alert) interface{} {
+	switch format {
+	case FormatSlack:
+		return map[string]interface{}{
+			"text": fmt.Sprintf("%s *%s*\n%s", emoji(alert), alert.Title, alert.Message),
+		}
+	case FormatDiscord:
+		return map[string]interface{}{
+			"embeds": []map[string]interface{}{{
+				"title":       fmt.Sprintf("%s %s", emoji(alert), alert.Title),
+				"description": alert.Message,
+				"color":       discordColors[alert.Severity],
+				"timestamp":   alert.Time.UTC().Format("2006-01-02T15:04:05Z"),
+			}},
+		}
+	default:
+		return alert
+	}
+}
+
+func emoji(alert Alert) string {
+	switch {
+	case alert.Resolved:
+		return "✅"
+	case alert.Severity == SeverityCritical:
+		return "🚨"
+	case alert.Severity == SeverityWarning:
+		return "⚠️"
+	default:
+		return "ℹ️"
+	}
+}
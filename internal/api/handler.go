@@ -2,12 +2,20 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"antigravity-lite/config"
 	"antigravity-lite/internal/account"
+	"antigravity-lite/internal/alerts"
+	"antigravity-lite/internal/audit"
+	"antigravity-lite/internal/jobs"
 	"antigravity-lite/internal/quota"
 	"antigravity-lite/internal/router"
 
@@ -19,20 +27,26 @@ type Handler struct {
 	accountMgr   *account.Manager
 	router       *router.Router
 	tracker      *quota.Tracker
+	auditLog     *audit.Logger
 	cfg          *config.Config
 	configPath   string
 	oauthHandler *account.OAuthHandler
+	jobs         *jobs.Scheduler
+	alerter      *alerts.Alerter
 }
 
 // NewHandler creates a new API handler
-func NewHandler(accountMgr *account.Manager, rt *router.Router, tracker *quota.Tracker, cfg *config.Config, configPath string) *Handler {
+func NewHandler(accountMgr *account.Manager, rt *router.Router, tracker *quota.Tracker, auditLog *audit.Logger, scheduler *jobs.Scheduler, alerter *alerts.Alerter, cfg *config.Config, configPath string) *Handler {
 	return &Handler{
 		accountMgr:   accountMgr,
 		router:       rt,
 		tracker:      tracker,
+		auditLog:     auditLog,
 		cfg:          cfg,
 		configPath:   configPath,
 		oauthHandler: account.NewOAuthHandler(accountMgr),
+		jobs:         scheduler,
+		alerter:      alerter,
 	}
 }
 
@@ -53,6 +67,12 @@ func (h *Handler) CreateAccount(c *gin.Context) {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
+	if input.Pacing != nil {
+		if err := input.Pacing.Validate(); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
 	acc, err := h.accountMgr.Create(input)
 	if err != nil {
@@ -60,6 +80,7 @@ func (h *Handler) CreateAccount(c *gin.Context) {
 		return
 	}
 
+	audit.Set(c, "account.create", acc.ID, nil, acc)
 	c.JSON(201, acc)
 }
 
@@ -93,6 +114,14 @@ func (h *Handler) UpdateAccount(c *gin.Context) {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
+	if input.Pacing != nil {
+		if err := input.Pacing.Validate(); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	before, _ := h.accountMgr.Get(id)
 
 	acc, err := h.accountMgr.Update(id, input)
 	if err != nil {
@@ -100,9 +129,181 @@ func (h *Handler) UpdateAccount(c *gin.Context) {
 		return
 	}
 
+	audit.Set(c, "account.update", id, before, acc)
+	c.JSON(200, acc)
+}
+
+// UpdateAccountTags replaces the pools an account belongs to
+func (h *Handler) UpdateAccountTags(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var input struct {
+		Tags []string `json:"tags"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	before, err := h.accountMgr.Get(id)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "account not found"})
+		return
+	}
+
+	acc, err := h.accountMgr.SetTags(id, input.Tags)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	audit.Set(c, "account.tags", id, gin.H{"tags": before.Tags}, gin.H{"tags": acc.Tags})
+	c.JSON(200, acc)
+}
+
+// DisableAccount pauses an account without deleting it
+func (h *Handler) DisableAccount(c *gin.Context) {
+	h.setAccountEnabled(c, false)
+}
+
+// EnableAccount resumes a paused account
+func (h *Handler) EnableAccount(c *gin.Context) {
+	h.setAccountEnabled(c, true)
+}
+
+func (h *Handler) setAccountEnabled(c *gin.Context, enabled bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid id"})
+		return
+	}
+
+	before, err := h.accountMgr.Get(id)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "account not found"})
+		return
+	}
+
+	var acc *account.Account
+	action := "account.disable"
+	if enabled {
+		acc, err = h.accountMgr.Enable(id)
+		action = "account.enable"
+	} else {
+		acc, err = h.accountMgr.Disable(id)
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	audit.Set(c, action, id, gin.H{"status": before.Status}, gin.H{"status": acc.Status})
+	c.JSON(200, acc)
+}
+
+// UpdateAccountWeight sets the scheduling weight of an account
+func (h *Handler) UpdateAccountWeight(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var input struct {
+		Weight int `json:"weight"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Weight < account.MinWeight || input.Weight > account.MaxWeight {
+		c.JSON(400, gin.H{"error": "weight must be between 1 and 1000"})
+		return
+	}
+
+	before, err := h.accountMgr.Get(id)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "account not found"})
+		return
+	}
+
+	acc, err := h.accountMgr.SetWeight(id, input.Weight)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	audit.Set(c, "account.weight", id, gin.H{"weight": before.Weight}, gin.H{"weight": acc.Weight})
+	c.JSON(200, acc)
+}
+
+// UpdateAccountPacing sets the pacing policy of an account. An empty policy
+// removes it.
+func (h *Handler) UpdateAccountPacing(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var policy account.PacingPolicy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := policy.Validate(); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	before, err := h.accountMgr.Get(id)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "account not found"})
+		return
+	}
+
+	acc, err := h.accountMgr.SetPacing(id, &policy)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	audit.Set(c, "account.pacing", id, gin.H{"pacing": before.Pacing}, gin.H{"pacing": acc.Pacing})
 	c.JSON(200, acc)
 }
 
+// ListPools returns each pool with its member accounts, and the routes and
+// client keys restricted to pools
+func (h *Handler) ListPools(c *gin.Context) {
+	accounts, err := h.accountMgr.List()
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	members := make(map[string][]int64)
+	for _, acc := range accounts {
+		for _, tag := range acc.Tags {
+			members[tag] = append(members[tag], acc.ID)
+		}
+	}
+
+	keys := make([]gin.H, 0, len(h.cfg.ClientKeys))
+	for _, ck := range h.cfg.ClientKeys {
+		keys = append(keys, gin.H{"name": ck.Name, "pools": ck.Pools})
+	}
+
+	c.JSON(200, gin.H{
+		"pools":       members,
+		"routes":      h.router.GetPools(),
+		"client_keys": keys,
+	})
+}
+
 // DeleteAccount deletes an account
 func (h *Handler) DeleteAccount(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
@@ -111,11 +312,14 @@ func (h *Handler) DeleteAccount(c *gin.Context) {
 		return
 	}
 
+	before, _ := h.accountMgr.Get(id)
+
 	if err := h.accountMgr.Delete(id); err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
 
+	audit.Set(c, "account.delete", id, before, nil)
 	c.JSON(204, nil)
 }
 
@@ -127,12 +331,15 @@ func (h *Handler) CheckAccount(c *gin.Context) {
 		return
 	}
 
+	before, _ := h.accountMgr.Get(id)
+
 	acc, err := h.accountMgr.CheckAccountStatus(id)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
 
+	audit.Set(c, "account.check", id, before, acc)
 	c.JSON(200, acc)
 }
 
@@ -144,6 +351,7 @@ func (h *Handler) CheckAllAccounts(c *gin.Context) {
 	}
 
 	accounts, _ := h.accountMgr.List()
+	audit.Set(c, "account.check_all", nil, nil, gin.H{"accounts": len(accounts)})
 	c.JSON(200, accounts)
 }
 
@@ -155,43 +363,108 @@ func (h *Handler) RefreshQuota(c *gin.Context) {
 		return
 	}
 
-	acc, err := h.accountMgr.Get(id)
+	before, err := h.accountMgr.Get(id)
 	if err != nil {
 		c.JSON(404, gin.H{"error": "account not found"})
 		return
 	}
 
-	// Ensure valid token
-	if err := h.accountMgr.EnsureValidToken(acc); err != nil {
-		c.JSON(500, gin.H{"error": "token refresh failed: " + err.Error()})
+	quotaData, err := h.accountMgr.RefreshQuota(id)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Fetch quota
-	fetcher := quota.NewQuotaFetcher()
-	quotaData, err := fetcher.FetchQuota(acc.AccessToken, "", acc.Email)
+	// The account type follows the subscription tier
+	if after, err := h.accountMgr.Get(id); err == nil && after.AccountType != before.AccountType {
+		audit.Set(c, "account.quota_refresh", id,
+			gin.H{"account_type": before.AccountType}, gin.H{"account_type": after.AccountType})
+	}
+
+	c.JSON(200, quotaData)
+}
+
+// maxQuotaHistoryHours bounds the quota history window
+const maxQuotaHistoryHours = 30 * 24
+
+// GetQuotaHistory returns the quota snapshots of an account grouped by model.
+// Query: hours (default 24) and an optional model.
+func (h *Handler) GetQuotaHistory(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "quota fetch failed: " + err.Error()})
+		c.JSON(400, gin.H{"error": "invalid id"})
 		return
 	}
 
-	// Update account in database with subscription tier
-	if quotaData.SubscriptionTier != "" {
-		// Map tier to account type
-		accountType := "free"
-		switch quotaData.SubscriptionTier {
-		case "ULTRA":
-			accountType = "ultra"
-		case "PRO":
-			accountType = "pro"
-		}
-		if accountType != acc.AccountType {
-			// Update account type if changed
-			_ = h.accountMgr.GetStorage().UpdateAccountType(acc.ID, accountType)
-		}
+	hours, err := strconv.Atoi(c.DefaultQuery("hours", "24"))
+	if err != nil || hours <= 0 || hours > maxQuotaHistoryHours {
+		c.JSON(400, gin.H{"error": "hours must be between 1 and 720"})
+		return
 	}
 
-	c.JSON(200, quotaData)
+	if _, err := h.accountMgr.Get(id); err != nil {
+		c.JSON(404, gin.H{"error": "account not found"})
+		return
+	}
+
+	snapshots, err := h.accountMgr.QuotaHistory(id, c.Query("model"), time.Now().Add(-time.Duration(hours)*time.Hour))
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	history := make(map[string][]account.ModelQuota)
+	for _, q := range snapshots {
+		history[q.Model] = append(history[q.Model], q)
+	}
+
+	c.JSON(200, gin.H{
+		"account_id": id,
+		"hours":      hours,
+		"history":    history,
+	})
+}
+
+// GetQuotaSummary returns the current quota of each model across active
+// accounts, optionally restricted to comma-separated pools
+func (h *Handler) GetQuotaSummary(c *gin.Context) {
+	var pools account.PoolFilter
+	if p := c.Query("pool"); p != "" {
+		pools = account.PoolFilter{account.NormalizeTags(strings.Split(p, ","))}
+	}
+
+	summary, err := h.accountMgr.QuotaSummary(pools)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, summary)
+}
+
+// maxQuotaForecastHours bounds the window rates are measured over
+const maxQuotaForecastHours = 48
+
+// GetQuotaForecast projects when each model runs out across active accounts,
+// optionally restricted to comma-separated pools. Rates are measured over the
+// last ?hours=3.
+func (h *Handler) GetQuotaForecast(c *gin.Context) {
+	hours, err := strconv.Atoi(c.DefaultQuery("hours", "3"))
+	if err != nil || hours <= 0 || hours > maxQuotaForecastHours {
+		c.JSON(400, gin.H{"error": "hours must be between 1 and 48"})
+		return
+	}
+
+	var pools account.PoolFilter
+	if p := c.Query("pool"); p != "" {
+		pools = account.PoolFilter{account.NormalizeTags(strings.Split(p, ","))}
+	}
+
+	forecast, err := h.accountMgr.QuotaForecast(pools, time.Duration(hours)*time.Hour)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, forecast)
 }
 
 // RefreshAllQuotas refreshes quota for all active accounts
@@ -202,7 +475,6 @@ func (h *Handler) RefreshAllQuotas(c *gin.Context) {
 		return
 	}
 
-	fetcher := quota.NewQuotaFetcher()
 	results := make([]interface{}, 0)
 
 	for _, acc := range accounts {
@@ -210,16 +482,7 @@ func (h *Handler) RefreshAllQuotas(c *gin.Context) {
 			continue
 		}
 
-		// Ensure valid token
-		if err := h.accountMgr.EnsureValidToken(&acc); err != nil {
-			results = append(results, gin.H{
-				"email": acc.Email,
-				"error": "token refresh failed",
-			})
-			continue
-		}
-
-		quotaData, err := fetcher.FetchQuota(acc.AccessToken, "", acc.Email)
+		quotaData, err := h.accountMgr.RefreshQuota(acc.ID)
 		if err != nil {
 			results = append(results, gin.H{
 				"email": acc.Email,
@@ -251,6 +514,7 @@ func (h *Handler) ImportAccounts(c *gin.Context) {
 		return
 	}
 
+	audit.Set(c, "account.import", nil, nil, gin.H{"imported": count})
 	c.JSON(200, gin.H{"imported": count})
 }
 
@@ -262,6 +526,9 @@ func (h *Handler) ExportAccounts(c *gin.Context) {
 		return
 	}
 
+	// Exports contain refresh tokens, so they are audited even though they are reads
+	audit.Set(c, "account.export", nil, nil, nil)
+
 	c.Header("Content-Type", "application/json")
 	c.Header("Content-Disposition", "attachment; filename=accounts.json")
 	c.Writer.Write(data)
@@ -273,31 +540,121 @@ func (h *Handler) GetRoutes(c *gin.Context) {
 	c.JSON(200, routes)
 }
 
-// UpdateRoutes updates model routes
+// routeUpdate is a route in a PUT /api/routes body: either just the target
+// model, which keeps the route's pools, or an object that also sets them
+type routeUpdate struct {
+	Target   string
+	Pools    []string
+	hasPools bool // pools were given, possibly empty to lift the restriction
+}
+
+func (u *routeUpdate) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &u.Target); err == nil {
+		return nil
+	}
+	var v struct {
+		Target string    `json:"target"`
+		Pools  *[]string `json:"pools"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("route must be a target model or {\"target\", \"pools\"}")
+	}
+	u.Target = v.Target
+	if v.Pools != nil {
+		u.Pools, u.hasPools = *v.Pools, true
+	}
+	return nil
+}
+
+// UpdateRoutes replaces the model routes
 func (h *Handler) UpdateRoutes(c *gin.Context) {
-	var routes map[string]string
-	if err := c.ShouldBindJSON(&routes); err != nil {
+	var updates map[string]routeUpdate
+	if err := c.ShouldBindJSON(&updates); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
+	routes := make(map[string]string, len(updates))
+	setPools := make(map[string][]string)
+	for pattern, u := range updates {
+		if u.Target == "" {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("route %q has no target", pattern)})
+			return
+		}
+		routes[pattern] = u.Target
+		if u.hasPools {
+			setPools[pattern] = u.Pools
+		}
+	}
+
+	before := h.routeConfigs()
 	h.router.SetRoutes(routes)
+	h.router.SetPools(setPools)
 
 	// Update config
-	h.cfg.Routes = nil
+	h.cfg.Routes = h.routeConfigs()
+	audit.Set(c, "routes.update", nil, before, h.cfg.Routes)
+
+	c.JSON(200, routes)
+}
+
+// routeConfigs returns the current routes with their pools, sorted by pattern
+func (h *Handler) routeConfigs() []config.RouteConfig {
+	routes := h.router.GetRoutes()
+	pools := h.router.GetPools()
+	configs := make([]config.RouteConfig, 0, len(routes))
 	for pattern, target := range routes {
-		h.cfg.Routes = append(h.cfg.Routes, config.RouteConfig{
-			Pattern: pattern,
-			Target:  target,
-		})
+		configs = append(configs, config.RouteConfig{Pattern: pattern, Target: target, Pools: pools[pattern]})
 	}
+	sort.Slice(configs, func(i, j int) bool { return configs[i].Pattern < configs[j].Pattern })
+	return configs
+}
 
-	c.JSON(200, routes)
+// statsRange reads ?from= and ?to= as RFC 3339 times or as dates in the
+// configured timezone. A date in to includes that whole day.
+func (h *Handler) statsRange(c *gin.Context) (quota.StatsRange, error) {
+	var r quota.StatsRange
+	loc := h.cfg.Location()
+	parse := func(name string, endOfDay bool) (time.Time, error) {
+		v := c.Query(name)
+		if v == "" {
+			return time.Time{}, nil
+		}
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t, nil
+		}
+		t, err := time.ParseInLocation("2006-01-02", v, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid %s, expected RFC 3339 time or YYYY-MM-DD", name)
+		}
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+
+	var err error
+	if r.From, err = parse("from", false); err != nil {
+		return r, err
+	}
+	if r.To, err = parse("to", true); err != nil {
+		return r, err
+	}
+	if !r.From.IsZero() && !r.To.IsZero() && !r.From.Before(r.To) {
+		return r, errors.New("from must be before to")
+	}
+	return r, nil
 }
 
-// GetStats returns usage statistics
+// GetStats returns usage statistics, optionally for ?from= and ?to=
 func (h *Handler) GetStats(c *gin.Context) {
-	stats, err := h.tracker.GetStats()
+	r, err := h.statsRange(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	stats, err := h.tracker.GetStats(r)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -305,9 +662,22 @@ func (h *Handler) GetStats(c *gin.Context) {
 	c.JSON(200, stats)
 }
 
-// GetModelStats returns per-model statistics
+// GetModelStats returns per-model statistics, optionally for ?from= and
+// ?to=, grouped by ?by=routed (default), requested or route
 func (h *Handler) GetModelStats(c *gin.Context) {
-	stats, err := h.tracker.GetModelStats()
+	r, err := h.statsRange(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	by := c.DefaultQuery("by", quota.ByRouted)
+	if by != quota.ByRouted && by != quota.ByRequested && by != quota.ByRoute {
+		c.JSON(400, gin.H{"error": "by must be routed, requested or route"})
+		return
+	}
+
+	stats, err := h.tracker.GetModelStats(r, by)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -315,9 +685,15 @@ func (h *Handler) GetModelStats(c *gin.Context) {
 	c.JSON(200, stats)
 }
 
-// GetAccountStats returns per-account statistics
+// GetAccountStats returns per-account statistics, optionally for ?from= and ?to=
 func (h *Handler) GetAccountStats(c *gin.Context) {
-	stats, err := h.tracker.GetAccountStats()
+	r, err := h.statsRange(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	stats, err := h.tracker.GetAccountStats(r)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -325,9 +701,22 @@ func (h *Handler) GetAccountStats(c *gin.Context) {
 	c.JSON(200, stats)
 }
 
-// GetHourlyStats returns hourly statistics
+// GetHourlyStats returns request counts over time, per ?granularity=minute,
+// hour (default) or day, for ?from= and ?to= or the last 24 hours
 func (h *Handler) GetHourlyStats(c *gin.Context) {
-	stats, err := h.tracker.GetHourlyStats()
+	r, err := h.statsRange(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	granularity := c.DefaultQuery("granularity", quota.GranularityHour)
+	if granularity != quota.GranularityMinute && granularity != quota.GranularityHour && granularity != quota.GranularityDay {
+		c.JSON(400, gin.H{"error": "granularity must be minute, hour or day"})
+		return
+	}
+
+	stats, err := h.tracker.GetTimeSeries(r, granularity)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -359,11 +748,33 @@ func (h *Handler) GetConfig(c *gin.Context) {
 
 // UpdateConfig updates configuration
 func (h *Handler) UpdateConfig(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 	var newCfg config.Config
-	if err := c.ShouldBindJSON(&newCfg); err != nil {
+	if err := json.Unmarshal(body, &newCfg); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
+	// Sections present in the body replace the stored ones as a whole, even
+	// when they decode to zero values such as {"enabled": false}
+	var sections map[string]json.RawMessage
+	json.Unmarshal(body, &sections)
+	provided := func(key string) bool {
+		raw, ok := sections[key]
+		return ok && string(raw) != "null"
+	}
+
+	if newCfg.Server.Timezone != "" {
+		if _, err := time.LoadLocation(newCfg.Server.Timezone); err != nil {
+			c.JSON(400, gin.H{"error": "invalid timezone " + newCfg.Server.Timezone})
+			return
+		}
+	}
+
+	before := *h.cfg
 
 	// Update server config fields
 	if newCfg.Server.Port > 0 {
@@ -381,12 +792,28 @@ func (h *Handler) UpdateConfig(c *gin.Context) {
 	h.cfg.Server.AuthEnabled = newCfg.Server.AuthEnabled
 	h.cfg.Server.LANAccess = newCfg.Server.LANAccess
 	h.cfg.Server.AutoStart = newCfg.Server.AutoStart
+	if newCfg.Server.Timezone != "" {
+		h.cfg.Server.Timezone = newCfg.Server.Timezone
+	}
 	if newCfg.Server.GoogleClientID != "" {
 		h.cfg.Server.GoogleClientID = newCfg.Server.GoogleClientID
 	}
 	if newCfg.Server.GoogleClientSecret != "" {
 		h.cfg.Server.GoogleClientSecret = newCfg.Server.GoogleClientSecret
 	}
+	// TLS settings are applied on restart; certificate contents reload automatically
+	if newCfg.Server.TLSCert != "" {
+		h.cfg.Server.TLSCert = newCfg.Server.TLSCert
+	}
+	if newCfg.Server.TLSKey != "" {
+		h.cfg.Server.TLSKey = newCfg.Server.TLSKey
+	}
+	if newCfg.Server.TLSClientCA != "" {
+		h.cfg.Server.TLSClientCA = newCfg.Server.TLSClientCA
+	}
+	if newCfg.Server.TLSClientAuth != "" {
+		h.cfg.Server.TLSClientAuth = newCfg.Server.TLSClientAuth
+	}
 
 	// Update proxy config fields
 	if newCfg.Proxy.Timeout > 0 {
@@ -402,6 +829,47 @@ func (h *Handler) UpdateConfig(c *gin.Context) {
 	if newCfg.Proxy.MaxWaitTime >= 0 {
 		h.cfg.Proxy.MaxWaitTime = newCfg.Proxy.MaxWaitTime
 	}
+	// 0 means "not provided"; send a negative value to remove the limit
+	if newCfg.Proxy.MaxConcurrency != 0 {
+		h.cfg.Proxy.MaxConcurrency = newCfg.Proxy.MaxConcurrency
+	}
+	if newCfg.Proxy.MaxConcurrencyByType != nil {
+		h.cfg.Proxy.MaxConcurrencyByType = newCfg.Proxy.MaxConcurrencyByType
+	}
+
+	// Update CORS policies if provided
+	if newCfg.CORS.Proxy.AllowedOrigins != nil {
+		h.cfg.CORS.Proxy = newCfg.CORS.Proxy
+	}
+	if newCfg.CORS.API.AllowedOrigins != nil {
+		h.cfg.CORS.API = newCfg.CORS.API
+	}
+
+	// Update rate limits if provided
+	if provided("rate_limit") {
+		h.cfg.RateLimit = newCfg.RateLimit
+	}
+
+	// Update job schedules if provided
+	if provided("jobs") {
+		h.cfg.Jobs = newCfg.Jobs
+	}
+
+	// Update alerting if provided
+	if provided("alerts") {
+		h.cfg.Alerts = newCfg.Alerts
+		h.alerter.SetConfig(h.cfg.Alerts)
+	}
+
+	// Update token budgets if provided; an empty list removes them
+	if newCfg.TokenBudgets != nil {
+		h.cfg.TokenBudgets = newCfg.TokenBudgets
+	}
+
+	// Update client key pool bindings if provided
+	if newCfg.ClientKeys != nil {
+		h.cfg.ClientKeys = newCfg.ClientKeys
+	}
 
 	// Update Host based on LANAccess
 	if h.cfg.Server.LANAccess {
@@ -410,6 +878,8 @@ func (h *Handler) UpdateConfig(c *gin.Context) {
 		h.cfg.Server.Host = "127.0.0.1"
 	}
 
+	audit.Set(c, "config.update", nil, before, *h.cfg)
+
 	// Save to config file
 	if err := config.Save(h.configPath, h.cfg); err != nil {
 		c.JSON(500, gin.H{"error": "Failed to save config: " + err.Error()})
@@ -423,12 +893,158 @@ func (h *Handler) UpdateConfig(c *gin.Context) {
 	})
 }
 
+// GetAuditLog returns audit log entries, filterable by actor, action, target and time range
+func (h *Handler) GetAuditLog(c *gin.Context) {
+	filter := audit.Filter{
+		Actor:    c.Query("actor"),
+		Action:   c.Query("action"),
+		TargetID: c.Query("target_id"),
+	}
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil {
+			filter.Limit = parsed
+		}
+	}
+	if from := c.Query("from"); from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "invalid from, expected RFC3339"})
+			return
+		}
+		filter.From = t
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "invalid to, expected RFC3339"})
+			return
+		}
+		filter.To = t
+	}
+
+	entries, err := h.auditLog.Query(filter)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, entries)
+}
+
+// ListJobs returns the background jobs with their schedules and progress
+func (h *Handler) ListJobs(c *gin.Context) {
+	c.JSON(200, h.jobs.List())
+}
+
+// GetJob returns a job's progress and its recent runs
+func (h *Handler) GetJob(c *gin.Context) {
+	name := c.Param("name")
+	status, err := h.jobs.Status(name)
+	if err != nil {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit := 20
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+	runs, err := h.jobs.Runs(name, limit)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"job":  status,
+		"runs": runs,
+	})
+}
+
+// RunJob starts a job now. Poll GetJob for its progress.
+func (h *Handler) RunJob(c *gin.Context) {
+	name := c.Param("name")
+	run, err := h.jobs.Trigger(name)
+	switch {
+	case errors.Is(err, jobs.ErrUnknownJob):
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, jobs.ErrJobRunning):
+		c.JSON(409, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	audit.Set(c, "job.run", name, nil, gin.H{"run_id": run.ID})
+	c.JSON(202, run)
+}
+
+// ListAlerts returns the alerts currently firing and the most recent ones
+func (h *Handler) ListAlerts(c *gin.Context) {
+	c.JSON(200, gin.H{
+		"firing": h.alerter.Firing(),
+		"recent": h.alerter.History(),
+	})
+}
+
+// TestAlerts sends a test alert to every configured webhook
+func (h *Handler) TestAlerts(c *gin.Context) {
+	if len(h.cfg.Alerts.Webhooks) == 0 {
+		c.JSON(400, gin.H{"error": "no webhooks configured"})
+		return
+	}
+
+	failures := make([]string, 0)
+	for _, err := range h.alerter.Test() {
+		failures = append(failures, err.Error())
+	}
+	audit.Set(c, "alerts.test", nil, nil, gin.H{"webhooks": len(h.cfg.Alerts.Webhooks), "failed": len(failures)})
+	c.JSON(200, gin.H{
+		"success": len(failures) == 0,
+		"errors":  failures,
+	})
+}
+
+// ListSessions returns session-to-account bindings
+func (h *Handler) ListSessions(c *gin.Context) {
+	c.JSON(200, h.accountMgr.ListSessions())
+}
+
+// ClearSessions removes all session bindings, or only those of ?account_id=
+func (h *Handler) ClearSessions(c *gin.Context) {
+	var accountID int64
+	if a := c.Query("account_id"); a != "" {
+		parsed, err := strconv.ParseInt(a, 10, 64)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "invalid account_id"})
+			return
+		}
+		accountID = parsed
+	}
+
+	removed := h.accountMgr.ClearSessions(accountID)
+	audit.Set(c, "sessions.clear", c.Query("account_id"), nil, gin.H{"removed": removed})
+	c.JSON(200, gin.H{"removed": removed})
+}
+
+// ClearSession removes a single session binding
+func (h *Handler) ClearSession(c *gin.Context) {
+	if !h.accountMgr.ClearSession(c.Param("id")) {
+		c.JSON(404, gin.H{"error": "session not found"})
+		return
+	}
+	audit.Set(c, "sessions.clear", c.Param("id"), nil, nil)
+	c.JSON(200, gin.H{"removed": 1})
+}
+
 // Dashboard returns dashboard data
 func (h *Handler) Dashboard(c *gin.Context) {
 	accounts, _ := h.accountMgr.List()
-	stats, _ := h.tracker.GetStats()
-	modelStats, _ := h.tracker.GetModelStats()
-	hourlyStats, _ := h.tracker.GetHourlyStats()
+	lastDay := quota.StatsRange{From: time.Now().Add(-24 * time.Hour)}
+	stats, _ := h.tracker.GetStats(lastDay)
+	modelStats, _ := h.tracker.GetModelStats(lastDay, quota.ByRouted)
+	hourlyStats, _ := h.tracker.GetTimeSeries(lastDay, quota.GranularityHour)
 
 	activeCount := 0
 	for _, acc := range accounts {
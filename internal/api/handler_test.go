@@ -0,0 +1,84 @@
+package api
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"antigravity-lite/config"
+	"antigravity-lite/internal/account"
+	"antigravity-lite/internal/alerts"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// putConfig sends body to UpdateConfig and returns the stored config
+func putConfig(t *testing.T, h *Handler, body string) *config.Config {
+	t.Helper()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("PUT", "/api/config", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	h.UpdateConfig(c)
+	if w.Code != 200 {
+		t.Fatalf("PUT %s: status %d: %s", body, w.Code, w.Body)
+	}
+	return h.cfg
+}
+
+func newConfigHandler(t *testing.T) *Handler {
+	cfg := config.DefaultConfig()
+	cfg.RateLimit = config.RateLimitConfig{
+		Enabled: true,
+		PerIP:   config.RateLimitRule{RequestsPerMinute: 60},
+	}
+	cfg.Jobs.Enabled = true
+	cfg.Alerts.Enabled = true
+
+	dir := t.TempDir()
+	storage, err := account.NewStorage(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := account.NewManager(storage, cfg)
+	return &Handler{
+		accountMgr: mgr,
+		cfg:        cfg,
+		configPath: filepath.Join(dir, "config.yaml"),
+		alerter:    alerts.NewAlerter(mgr, cfg),
+	}
+}
+
+func TestUpdateConfigDisablesSections(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		enabled func(cfg *config.Config) bool
+	}{
+		{"rate limits", `{"rate_limit": {"enabled": false}}`, func(cfg *config.Config) bool { return cfg.RateLimit.Enabled }},
+		{"jobs", `{"jobs": {"enabled": false}}`, func(cfg *config.Config) bool { return cfg.Jobs.Enabled }},
+		{"alerts", `{"alerts": {"enabled": false}}`, func(cfg *config.Config) bool { return cfg.Alerts.Enabled }},
+	}
+	for _, tt := range tests {
+		h := newConfigHandler(t)
+		if !tt.enabled(h.cfg) {
+			t.Fatalf("%s: not enabled before the update", tt.name)
+		}
+		if cfg := putConfig(t, h, tt.body); tt.enabled(cfg) {
+			t.Errorf("%s: still enabled after %s", tt.name, tt.body)
+		}
+	}
+}
+
+func TestUpdateConfigKeepsMissingSections(t *testing.T) {
+	h := newConfigHandler(t)
+	cfg := putConfig(t, h, `{"proxy": {"timeout": 30}}`)
+	if !cfg.RateLimit.Enabled || cfg.RateLimit.PerIP.RequestsPerMinute != 60 {
+		t.Errorf("rate limits changed by an update without them: %+v", cfg.RateLimit)
+	}
+}
@@ -0,0 +1,358 @@
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const contextKey = "audit.detail"
+
+// Redacted replaces secret values in audit diffs
+const Redacted = "[REDACTED]"
+
+// Entry represents a single audit log record
+type Entry struct {
+	ID         int64                  `json:"id"`
+	Actor      string                 `json:"actor"`
+	ClientIP   string                 `json:"client_ip"`
+	Action     string                 `json:"action"`
+	TargetID   string                 `json:"target_id,omitempty"`
+	Diff       map[string]interface{} `json:"diff,omitempty"`
+	StatusCode int                    `json:"status_code"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+// Filter narrows down audit log queries
+type Filter struct {
+	Actor    string
+	Action   string
+	TargetID string
+	From     time.Time
+	To       time.Time
+	Limit    int
+}
+
+// detail is what a handler attaches to the request for the middleware to record
+type detail struct {
+	action   string
+	targetID string
+	before   interface{}
+	after    interface{}
+}
+
+// Logger writes and queries the audit log
+type Logger struct {
+	db          *sql.DB
+	apiKeyFn    func() string
+	clientKeyFn func(key string) string
+}
+
+// NewLogger creates a new audit logger. apiKeyFn returns the configured admin
+// API key so requests made with it can be attributed to the admin;
+// clientKeyFn returns the name of a configured client key, or "" when key is
+// not one.
+func NewLogger(db *sql.DB, apiKeyFn func() string, clientKeyFn func(key string) string) *Logger {
+	return &Logger{db: db, apiKeyFn: apiKeyFn, clientKeyFn: clientKeyFn}
+}
+
+// Set attaches action details to the request. before/after are any JSON-encodable
+// values; only changed fields end up in the stored diff, with secrets redacted.
+func Set(c *gin.Context, action string, targetID interface{}, before, after interface{}) {
+	id := ""
+	if targetID != nil {
+		id = fmt.Sprint(targetID)
+	}
+	c.Set(contextKey, &detail{action: action, targetID: id, before: before, after: after})
+}
+
+// Middleware records an entry for every mutating request, and for any
+// read request whose handler explicitly called Set
+func (l *Logger) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		var d *detail
+		if v, ok := c.Get(contextKey); ok {
+			d, _ = v.(*detail)
+		}
+
+		method := c.Request.Method
+		if d == nil && (method == "GET" || method == "HEAD" || method == "OPTIONS") {
+			return
+		}
+		if d == nil {
+			d = &detail{action: method + " " + c.FullPath(), targetID: c.Param("id")}
+		}
+
+		entry := Entry{
+			Actor:      l.actor(c),
+			ClientIP:   c.ClientIP(),
+			Action:     d.action,
+			TargetID:   d.targetID,
+			Diff:       Diff(d.before, d.after),
+			StatusCode: c.Writer.Status(),
+		}
+		if err := l.Record(entry); err != nil {
+			log.Printf("audit: failed to record %s: %v", entry.Action, err)
+		}
+	}
+}
+
+// actor identifies who made the request: the admin key, a client key by
+// name, another key masked, or the client IP when no key was sent
+func (l *Logger) actor(c *gin.Context) string {
+	key := c.GetHeader("X-API-Key")
+	if key == "" {
+		key = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	}
+
+	if key == "" {
+		return "ip:" + c.ClientIP()
+	}
+	if l.apiKeyFn != nil && key == l.apiKeyFn() {
+		return "key:admin"
+	}
+	if l.clientKeyFn != nil {
+		if name := l.clientKeyFn(key); name != "" {
+			return "client:" + name
+		}
+	}
+	return "key:" + maskKey(key)
+}
+
+// maskKey keeps only enough of a key to tell keys apart
+func maskKey(key string) string {
+	if len(key) <= 10 {
+		return "***"
+	}
+	return key[:6] + "..." + key[len(key)-4:]
+}
+
+// Record writes an entry to the audit log
+func (l *Logger) Record(e Entry) error {
+	var diff []byte
+	if len(e.Diff) > 0 {
+		diff, _ = json.Marshal(e.Diff)
+	}
+
+	_, err := l.db.Exec(`
+		INSERT INTO audit_log (actor, client_ip, action, target_id, diff, status_code)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, e.Actor, e.ClientIP, e.Action, e.TargetID, string(diff), e.StatusCode)
+	return err
+}
+
+// Query returns audit entries matching the filter, newest first
+func (l *Logger) Query(f Filter) ([]Entry, error) {
+	query := `SELECT id, actor, client_ip, action, target_id, diff, status_code, created_at FROM audit_log WHERE 1=1`
+	var args []interface{}
+
+	if f.Actor != "" {
+		query += " AND actor = ?"
+		args = append(args, f.Actor)
+	}
+	if f.Action != "" {
+		query += " AND action LIKE ?"
+		args = append(args, f.Action+"%")
+	}
+	if f.TargetID != "" {
+		query += " AND target_id = ?"
+		args = append(args, f.TargetID)
+	}
+	if !f.From.IsZero() {
+		query += " AND created_at >= ?"
+		args = append(args, dbTime(f.From))
+	}
+	if !f.To.IsZero() {
+		query += " AND created_at < ?"
+		args = append(args, dbTime(f.To))
+	}
+
+	if f.Limit <= 0 || f.Limit > 1000 {
+		f.Limit = 100
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, f.Limit)
+
+	rows, err := l.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]Entry, 0)
+	for rows.Next() {
+		var e Entry
+		var actor, clientIP, targetID, diff sql.NullString
+		var statusCode sql.NullInt64
+		if err := rows.Scan(&e.ID, &actor, &clientIP, &e.Action, &targetID, &diff, &statusCode, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Actor = actor.String
+		e.ClientIP = clientIP.String
+		e.TargetID = targetID.String
+		e.StatusCode = int(statusCode.Int64)
+		if diff.String != "" {
+			_ = json.Unmarshal([]byte(diff.String), &e.Diff)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// dbTime formats a time like SQLite's CURRENT_TIMESTAMP so it compares as text
+// against created_at
+func dbTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+// Diff returns the changed fields between before and after as
+// {"path": {"before": x, "after": y}}, with secret fields redacted.
+// Nested objects are flattened into dotted paths.
+func Diff(before, after interface{}) map[string]interface{} {
+	if before == nil && after == nil {
+		return nil
+	}
+
+	b := flatten(toMap(before))
+	a := flatten(toMap(after))
+
+	keys := make(map[string]struct{})
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	diff := make(map[string]interface{})
+	for _, k := range sorted {
+		bv, bok := b[k]
+		av, aok := a[k]
+		if bok && aok && reflect.DeepEqual(bv, av) {
+			continue
+		}
+
+		change := map[string]interface{}{}
+		if bok {
+			change["before"] = redact(k, bv)
+		}
+		if aok {
+			change["after"] = redact(k, av)
+		}
+		diff[k] = change
+	}
+
+	if len(diff) == 0 {
+		return nil
+	}
+	return diff
+}
+
+// toMap converts any JSON-encodable value into a generic map
+func toMap(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		// Not an object, store it under a single key
+		var raw interface{}
+		_ = json.Unmarshal(data, &raw)
+		return map[string]interface{}{"value": raw}
+	}
+	return m
+}
+
+// flatten turns nested maps into dotted keys and lists of objects into
+// indexed keys, e.g. "client_keys[0].key". Lists of plain values are kept
+// whole.
+func flatten(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{})
+	for k, v := range m {
+		flattenValue(out, k, v)
+	}
+	return out
+}
+
+func flattenValue(out map[string]interface{}, key string, v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) > 0 {
+			for nk, nv := range v {
+				flattenValue(out, key+"."+nk, nv)
+			}
+			return
+		}
+	case []interface{}:
+		if hasObjects(v) {
+			for i, item := range v {
+				flattenValue(out, fmt.Sprintf("%s[%d]", key, i), item)
+			}
+			return
+		}
+	}
+	out[key] = v
+}
+
+// hasObjects reports whether a list holds objects or lists
+func hasObjects(list []interface{}) bool {
+	for _, item := range list {
+		switch item.(type) {
+		case map[string]interface{}, []interface{}:
+			return true
+		}
+	}
+	return false
+}
+
+// redact hides values of fields that look like credentials
+func redact(key string, v interface{}) interface{} {
+	if v == nil || v == "" {
+		return v
+	}
+	if IsSecretField(key) {
+		return Redacted
+	}
+	return v
+}
+
+// IsSecretField reports whether a (possibly dotted or indexed) field name
+// holds a credential. Webhook URLs count: for Slack and Discord the URL is
+// the credential.
+func IsSecretField(key string) bool {
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		key = key[i+1:]
+	}
+	if i := strings.Index(key, "["); i >= 0 {
+		key = key[:i]
+	}
+	key = strings.ToLower(key)
+
+	for _, suffix := range []string{"token", "secret", "password", "key", "keys", "url", "webhook", "webhooks"} {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,186 @@
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"antigravity-lite/config"
+
+	"github.com/gin-gonic/gin"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestDiffRedactsConfigSecrets(t *testing.T) {
+	before := &config.Config{
+		ClientKeys: []config.ClientKeyConfig{
+			{Name: "interns", Key: "sk-intern-old", Pools: []string{"burner"}},
+		},
+	}
+	after := &config.Config{
+		ClientKeys: []config.ClientKeyConfig{
+			{Name: "interns", Key: "sk-intern-new", Pools: []string{"burner"}},
+			{Name: "team", Key: "sk-team", Pools: []string{"team"}},
+		},
+		Alerts: config.AlertsConfig{
+			Webhooks: []config.WebhookConfig{
+				{Name: "ops", URL: "https://hooks.slack.com/services/T0/B0/secret", Format: "slack"},
+			},
+		},
+	}
+
+	diff := Diff(before, after)
+	data, err := json.Marshal(diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"sk-intern-old", "sk-intern-new", "sk-team", "hooks.slack.com"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("diff leaks %q: %s", secret, data)
+		}
+	}
+
+	tests := []struct {
+		field  string
+		before interface{}
+		after  interface{}
+	}{
+		{"client_keys[0].key", Redacted, Redacted},
+		{"client_keys[1].key", nil, Redacted},
+		{"client_keys[1].name", nil, "team"},
+		{"alerts.webhooks[0].url", nil, Redacted},
+		{"alerts.webhooks[0].format", nil, "slack"},
+	}
+	for _, tt := range tests {
+		change, ok := diff[tt.field].(map[string]interface{})
+		if !ok {
+			t.Errorf("%s: not in diff %v", tt.field, diff)
+			continue
+		}
+		if change["before"] != tt.before {
+			t.Errorf("%s: before = %v, want %v", tt.field, change["before"], tt.before)
+		}
+		if change["after"] != tt.after {
+			t.Errorf("%s: after = %v, want %v", tt.field, change["after"], tt.after)
+		}
+	}
+
+	if _, ok := diff["client_keys[0].name"]; ok {
+		t.Errorf("unchanged field client_keys[0].name is in the diff")
+	}
+}
+
+func TestIsSecretField(t *testing.T) {
+	tests := []struct {
+		field string
+		want  bool
+	}{
+		{"refresh_token", true},
+		{"account.access_token", true},
+		{"client_secret", true},
+		{"server.api_key", true},
+		{"client_keys", true},
+		{"client_keys[2].key", true},
+		{"alerts.webhooks[0].url", true},
+		{"webhook", true},
+		{"client_keys[2].name", false},
+		{"client_keys[2].pools", false},
+		{"email", false},
+		{"tags", false},
+	}
+	for _, tt := range tests {
+		if got := IsSecretField(tt.field); got != tt.want {
+			t.Errorf("IsSecretField(%q) = %v, want %v", tt.field, got, tt.want)
+		}
+	}
+}
+
+func TestQueryTimeRange(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		actor TEXT,
+		client_ip TEXT,
+		action TEXT NOT NULL,
+		target_id TEXT,
+		diff TEXT,
+		status_code INTEGER,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		t.Fatal(err)
+	}
+
+	l := NewLogger(db, nil, nil)
+	if err := l.Record(Entry{Action: "account.update"}); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := l.Query(Filter{})
+	if err != nil || len(all) != 1 {
+		t.Fatalf("Query() = %v, %v", all, err)
+	}
+	created := all[0].CreatedAt
+
+	now := time.Now()
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     int
+	}{
+		{"no range", time.Time{}, time.Time{}, 1},
+		{"around now", now.Add(-time.Minute), now.Add(time.Minute), 1},
+		{"from later", now.Add(time.Minute), time.Time{}, 0},
+		{"to earlier", time.Time{}, now.Add(-time.Minute), 0},
+		{"from the same second", created, time.Time{}, 1},
+		{"to the same second", time.Time{}, created, 0},
+		{"other timezone", now.Add(-time.Minute).In(time.FixedZone("UTC+8", 8*3600)), time.Time{}, 1},
+	}
+	for _, tt := range tests {
+		entries, err := l.Query(Filter{From: tt.from, To: tt.to})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != tt.want {
+			t.Errorf("%s: got %d entries, want %d", tt.name, len(entries), tt.want)
+		}
+	}
+}
+
+func TestActor(t *testing.T) {
+	l := NewLogger(nil,
+		func() string { return "admin-key-123456" },
+		func(key string) string {
+			if key == "sk-team-123456" {
+				return "team"
+			}
+			return ""
+		})
+
+	tests := []struct {
+		header, value string
+		want          string
+	}{
+		{"", "", "ip:192.0.2.1"},
+		{"X-API-Key", "admin-key-123456", "key:admin"},
+		{"Authorization", "Bearer sk-team-123456", "client:team"},
+		{"Authorization", "Bearer sk-other-987654", "key:sk-oth...7654"},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("PUT", "/api/config", nil)
+		c.Request.RemoteAddr = "192.0.2.1:1234"
+		if tt.header != "" {
+			c.Request.Header.Set(tt.header, tt.value)
+		}
+		if got := l.actor(c); got != tt.want {
+			t.Errorf("actor with %s %q = %q, want %q", tt.header, tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package jobs
+
+import (
+	"fmt"
+	"time"
+
+	"antigravity-lite/config"
+	"antigravity-lite/internal/account"
+)
+
+// Built-in job names
+const (
+	JobHealthCheck    = "health_check"
+	JobQuotaRefresh   = "quota_refresh"
+	JobExpiredRecheck = "expired_recheck"
+)
+
+func builtinJobs() []Job {
+	return []Job{
+		{
+			Name:        JobHealthCheck,
+			Description: "Check the status of every account that is not paused or expired",
+			Interval: func(cfg *config.Config) time.Duration {
+				return minutes(cfg.Jobs.HealthCheckInterval)
+			},
+			Targets: func(mgr *account.Manager) ([]account.Account, error) {
+				return accountsWhere(mgr, func(acc account.Account) bool {
+					return acc.Status != account.StatusDisabled && acc.Status != account.StatusExpired
+				})
+			},
+			Process: checkAccount,
+		},
+		{
+			Name:        JobQuotaRefresh,
+			Description: "Fetch the quota of every active account",
+			Interval: func(cfg *config.Config) time.Duration {
+				return minutes(cfg.Jobs.QuotaRefreshInterval)
+			},
+			Targets: func(mgr *account.Manager) ([]account.Account, error) {
+				return accountsWhere(mgr, func(acc account.Account) bool {
+					return acc.Status == account.StatusActive
+				})
+			},
+			Process: func(mgr *account.Manager, acc account.Account) error {
+				_, err := mgr.RefreshQuota(acc.ID)
+				return err
+			},
+		},
+		{
+			Name:        JobExpiredRecheck,
+			Description: "Re-check expired accounts in case their tokens work again",
+			Interval: func(cfg *config.Config) time.Duration {
+				return minutes(cfg.Jobs.ExpiredRecheckInterval)
+			},
+			Targets: func(mgr *account.Manager) ([]account.Account, error) {
+				return accountsWhere(mgr, func(acc account.Account) bool {
+					return acc.Status == account.StatusExpired
+				})
+			},
+			Process: checkAccount,
+		},
+	}
+}
+
+// checkAccount runs a status check, keeping the account in rotation while it
+// runs, and fails unless the account ends up active
+func checkAccount(mgr *account.Manager, acc account.Account) error {
+	checked, err := mgr.RecheckAccountStatus(acc.ID)
+	if err != nil {
+		return err
+	}
+	if checked.Status != account.StatusActive {
+		return fmt.Errorf("status %s", checked.Status)
+	}
+	return nil
+}
+
+// accountsWhere lists the accounts matching a predicate
+func accountsWhere(mgr *account.Manager, match func(account.Account) bool) ([]account.Account, error) {
+	accounts, err := mgr.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []account.Account
+	for _, acc := range accounts {
+		if match(acc) {
+			matched = append(matched, acc)
+		}
+	}
+	return matched, nil
+}
+
+func minutes(n int) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	return time.Duration(n) * time.Minute
+}
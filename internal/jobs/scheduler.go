@@ -0,0 +1,443 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"antigravity-lite/config"
+	"antigravity-lite/internal/account"
+)
+
+// Run status values
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusPartial   = "partial" // some accounts failed
+	StatusFailed    = "failed"  // the job could not run or every account failed
+)
+
+// Run trigger values
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+// maxRunErrors caps the per-account errors kept on a run
+const maxRunErrors = 20
+
+// idleRecheck is how often a job with a disabled schedule re-reads the config
+const idleRecheck = time.Minute
+
+var (
+	ErrUnknownJob = errors.New("unknown job")
+	ErrJobRunning = errors.New("job is already running")
+)
+
+// Job is a maintenance task applied to a set of accounts
+type Job struct {
+	Name        string
+	Description string
+	// Interval returns the current schedule; 0 disables it
+	Interval func(cfg *config.Config) time.Duration
+	// Targets lists the accounts the job processes
+	Targets func(mgr *account.Manager) ([]account.Account, error)
+	// Process handles one account; an error counts the account as failed
+	Process func(mgr *account.Manager, acc account.Account) error
+}
+
+// Run records one execution of a job. While running, Succeeded and Failed
+// show the progress.
+type Run struct {
+	ID          int64     `json:"id"`
+	Job         string    `json:"job"`
+	TriggeredBy string    `json:"triggered_by"`
+	Status      string    `json:"status"`
+	Total       int       `json:"total"`
+	Succeeded   int       `json:"succeeded"`
+	Failed      int       `json:"failed"`
+	Errors      []string  `json:"errors,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	FinishedAt  time.Time `json:"finished_at"`
+}
+
+// JobStatus describes a job, its schedule and its current or last run
+type JobStatus struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Interval    int       `json:"interval"` // minutes, 0 when not scheduled
+	NextRunAt   time.Time `json:"next_run_at"`
+	Running     *Run      `json:"running,omitempty"`
+	LastRun     *Run      `json:"last_run,omitempty"`
+}
+
+type jobState struct {
+	job     Job
+	running *Run
+	lastRun *Run
+	nextRun time.Time
+}
+
+// Scheduler runs the maintenance jobs on their schedules and on demand
+type Scheduler struct {
+	db     *sql.DB
+	mgr    *account.Manager
+	cfg    *config.Config
+	mu     sync.Mutex
+	jobs   map[string]*jobState
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewScheduler creates a scheduler with the built-in jobs. Call Start to
+// run them on their schedules.
+func NewScheduler(db *sql.DB, mgr *account.Manager, cfg *config.Config) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Scheduler{
+		db:     db,
+		mgr:    mgr,
+		cfg:    cfg,
+		jobs:   make(map[string]*jobState),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	for _, job := range builtinJobs() {
+		s.jobs[job.Name] = &jobState{job: job}
+	}
+	s.loadLastRuns()
+	return s
+}
+
+// Start launches the schedule loops
+func (s *Scheduler) Start() {
+	for _, st := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(st)
+	}
+}
+
+// Stop cancels running jobs and waits for them to finish
+func (s *Scheduler) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// loop runs a job each time its interval elapses. The interval is read from
+// the config every cycle so changes apply without a restart.
+func (s *Scheduler) loop(st *jobState) {
+	defer s.wg.Done()
+
+	for {
+		wait := idleRecheck
+		interval := s.interval(st.job)
+		if interval > 0 {
+			s.mu.Lock()
+			if st.nextRun.IsZero() {
+				st.nextRun = time.Now().Add(interval)
+			}
+			wait = time.Until(st.nextRun)
+			s.mu.Unlock()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if s.interval(st.job) == 0 {
+			s.mu.Lock()
+			st.nextRun = time.Time{}
+			s.mu.Unlock()
+			continue
+		}
+		s.mu.Lock()
+		due := !time.Now().Before(st.nextRun)
+		s.mu.Unlock()
+		if !due {
+			continue
+		}
+
+		run, err := s.begin(st, TriggerSchedule)
+		if err != nil {
+			// A manual run is in progress; try again next interval
+			s.mu.Lock()
+			st.nextRun = time.Now().Add(s.interval(st.job))
+			s.mu.Unlock()
+			continue
+		}
+		s.execute(st, run)
+	}
+}
+
+// interval returns the configured schedule of a job, 0 when disabled
+func (s *Scheduler) interval(job Job) time.Duration {
+	if s.cfg == nil || !s.cfg.Jobs.Enabled {
+		return 0
+	}
+	return job.Interval(s.cfg)
+}
+
+// Trigger starts a job in the background and returns its run
+func (s *Scheduler) Trigger(name string) (*Run, error) {
+	st, ok := s.jobs[name]
+	if !ok {
+		return nil, ErrUnknownJob
+	}
+
+	run, err := s.begin(st, TriggerManual)
+	if err != nil {
+		return nil, err
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.execute(st, run)
+	}()
+
+	snapshot := *run
+	return &snapshot, nil
+}
+
+// begin marks a job as running and records the run
+func (s *Scheduler) begin(st *jobState, trigger string) (*Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if st.running != nil {
+		return nil, ErrJobRunning
+	}
+
+	run := &Run{
+		Job:         st.job.Name,
+		TriggeredBy: trigger,
+		Status:      StatusRunning,
+		StartedAt:   time.Now(),
+	}
+	result, err := s.db.Exec(`
+		INSERT INTO job_runs (job, triggered_by, status, started_at)
+		VALUES (?, ?, ?, ?)
+	`, run.Job, run.TriggeredBy, run.Status, run.StartedAt.UTC())
+	if err != nil {
+		log.Printf("Failed to record %s run: %v", run.Job, err)
+	} else {
+		run.ID, _ = result.LastInsertId()
+	}
+
+	st.running = run
+	return run, nil
+}
+
+// execute processes every target of the job with bounded parallelism and
+// records the outcome
+func (s *Scheduler) execute(st *jobState, run *Run) {
+	targets, err := st.job.Targets(s.mgr)
+	if err != nil {
+		s.mu.Lock()
+		run.Errors = append(run.Errors, err.Error())
+		s.mu.Unlock()
+	}
+
+	s.mu.Lock()
+	run.Total = len(targets)
+	s.mu.Unlock()
+
+	workers := 1
+	if s.cfg != nil && s.cfg.Jobs.Workers > 1 {
+		workers = s.cfg.Jobs.Workers
+	}
+
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for _, acc := range targets {
+		select {
+		case <-s.ctx.Done():
+		case sem <- struct{}{}:
+		}
+		if s.ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(acc account.Account) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := st.job.Process(s.mgr, acc)
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if err != nil {
+				run.Failed++
+				if len(run.Errors) < maxRunErrors {
+					run.Errors = append(run.Errors, fmt.Sprintf("%s: %v", accountLabel(acc), err))
+				}
+				return
+			}
+			run.Succeeded++
+		}(acc)
+	}
+	wg.Wait()
+
+	s.finish(st, run, err)
+}
+
+// finish stores the outcome of a run and schedules the next one
+func (s *Scheduler) finish(st *jobState, run *Run, targetsErr error) {
+	s.mu.Lock()
+	run.FinishedAt = time.Now()
+	switch {
+	case targetsErr != nil || (run.Total > 0 && run.Succeeded == 0):
+		run.Status = StatusFailed
+	case run.Failed > 0 || run.Succeeded+run.Failed < run.Total:
+		run.Status = StatusPartial
+	default:
+		run.Status = StatusSucceeded
+	}
+	finished := *run
+	st.running = nil
+	st.lastRun = &finished
+	if interval := s.interval(st.job); interval > 0 {
+		st.nextRun = run.FinishedAt.Add(interval)
+	}
+	s.mu.Unlock()
+
+	errs, _ := json.Marshal(finished.Errors)
+	if _, err := s.db.Exec(`
+		UPDATE job_runs SET status = ?, total = ?, succeeded = ?, failed = ?, errors = ?, finished_at = ?
+		WHERE id = ?
+	`, finished.Status, finished.Total, finished.Succeeded, finished.Failed, string(errs),
+		finished.FinishedAt.UTC(), finished.ID); err != nil {
+		log.Printf("Failed to record %s run: %v", finished.Job, err)
+	}
+
+	log.Printf("Job %s %s: %d/%d succeeded, %d failed", finished.Job, finished.Status,
+		finished.Succeeded, finished.Total, finished.Failed)
+}
+
+// List returns the status of every job, sorted by name
+func (s *Scheduler) List() []JobStatus {
+	names := make([]string, 0, len(s.jobs))
+	for name := range s.jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	statuses := make([]JobStatus, 0, len(names))
+	for _, name := range names {
+		status, _ := s.Status(name)
+		statuses = append(statuses, *status)
+	}
+	return statuses
+}
+
+// Status returns a job's schedule and its current or last run
+func (s *Scheduler) Status(name string) (*JobStatus, error) {
+	st, ok := s.jobs[name]
+	if !ok {
+		return nil, ErrUnknownJob
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := &JobStatus{
+		Name:        st.job.Name,
+		Description: st.job.Description,
+		Interval:    int(s.interval(st.job) / time.Minute),
+		NextRunAt:   st.nextRun,
+	}
+	if st.running != nil {
+		running := *st.running
+		running.Errors = append([]string(nil), running.Errors...)
+		status.Running = &running
+	}
+	if st.lastRun != nil {
+		last := *st.lastRun
+		status.LastRun = &last
+	}
+	return status, nil
+}
+
+// Runs returns the most recent runs of a job, newest first
+func (s *Scheduler) Runs(name string, limit int) ([]Run, error) {
+	if _, ok := s.jobs[name]; !ok {
+		return nil, ErrUnknownJob
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, job, COALESCE(triggered_by, ''), COALESCE(status, ''), total, succeeded, failed,
+		       COALESCE(errors, ''), started_at, finished_at
+		FROM job_runs WHERE job = ? ORDER BY id DESC LIMIT ?
+	`, name, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := make([]Run, 0)
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// loadLastRuns restores the last finished run of each job and marks runs
+// interrupted by a shutdown as failed
+func (s *Scheduler) loadLastRuns() {
+	if _, err := s.db.Exec(`
+		UPDATE job_runs SET status = ?, finished_at = started_at WHERE status = ?
+	`, StatusFailed, StatusRunning); err != nil {
+		log.Printf("Failed to close interrupted job runs: %v", err)
+	}
+
+	for name, st := range s.jobs {
+		runs, err := s.Runs(name, 1)
+		if err != nil || len(runs) == 0 {
+			continue
+		}
+		st.lastRun = &runs[0]
+	}
+}
+
+func scanRun(rows *sql.Rows) (Run, error) {
+	var run Run
+	var errs string
+	var startedAt, finishedAt sql.NullTime
+	if err := rows.Scan(&run.ID, &run.Job, &run.TriggeredBy, &run.Status, &run.Total, &run.Succeeded,
+		&run.Failed, &errs, &startedAt, &finishedAt); err != nil {
+		return run, err
+	}
+	if errs != "" {
+		_ = json.Unmarshal([]byte(errs), &run.Errors)
+	}
+	if startedAt.Valid {
+		run.StartedAt = startedAt.Time
+	}
+	if finishedAt.Valid {
+		run.FinishedAt = finishedAt.Time
+	}
+	return run, nil
+}
+
+// accountLabel names an account in run errors
+func accountLabel(acc account.Account) string {
+	if acc.Email != "" {
+		return acc.Email
+	}
+	return fmt.Sprintf("account %d", acc.ID)
+}
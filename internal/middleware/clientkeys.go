@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"crypto/subtle"
+
+	"antigravity-lite/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+const clientKeyContextKey = "clientkeys.match"
+
+// ClientKeys authenticates proxy requests against the configured client keys.
+// Once any key is configured, requests whose path starts with one of the
+// prefixes must carry one of them or are rejected with 401; with no keys
+// configured every request is let through. The config is read on every
+// request so keys can be changed without a restart.
+func ClientKeys(cfg *config.Config, prefixes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(cfg.ClientKeys) == 0 || c.Request.Method == "OPTIONS" || !hasPrefix(c.Request.URL.Path, prefixes) {
+			c.Next()
+			return
+		}
+
+		ck := MatchClientKey(cfg.ClientKeys, ClientKey(c))
+		if ck == nil {
+			writeError(c, 401, "Invalid or missing API key")
+			return
+		}
+		c.Set(clientKeyContextKey, ck)
+		c.Next()
+	}
+}
+
+// AuthenticatedClientKey returns the client key the request was
+// authenticated with by ClientKeys, or nil
+func AuthenticatedClientKey(c *gin.Context) *config.ClientKeyConfig {
+	if v, ok := c.Get(clientKeyContextKey); ok {
+		return v.(*config.ClientKeyConfig)
+	}
+	return nil
+}
+
+// MatchClientKey returns the entry whose key equals key, or nil
+func MatchClientKey(keys []config.ClientKeyConfig, key string) *config.ClientKeyConfig {
+	if key == "" {
+		return nil
+	}
+	for i := range keys {
+		if keys[i].Key != "" && subtle.ConstantTimeCompare([]byte(keys[i].Key), []byte(key)) == 1 {
+			ck := keys[i]
+			return &ck
+		}
+	}
+	return nil
+}
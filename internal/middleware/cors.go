@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"antigravity-lite/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORS applies a CORS policy to requests whose path starts with one of the prefixes.
+// The policy is read on every request so config updates take effect immediately.
+func CORS(policy *config.CORSPolicy, prefixes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !hasPrefix(c.Request.URL.Path, prefixes) {
+			c.Next()
+			return
+		}
+
+		origin := c.Request.Header.Get("Origin")
+		preflight := c.Request.Method == "OPTIONS" && c.Request.Header.Get("Access-Control-Request-Method") != ""
+
+		// Not a cross-origin request (curl, SDKs, same-origin navigation)
+		if origin == "" {
+			if preflight {
+				c.AbortWithStatus(204)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		allowed, wildcard := originAllowed(policy, origin, c.Request.Host)
+		if !allowed {
+			// Refuse outright so a foreign page can't trigger side effects
+			// with a "simple" request that skips preflight
+			c.AbortWithStatusJSON(403, gin.H{"error": "origin not allowed"})
+			return
+		}
+
+		if wildcard && !policy.AllowCredentials {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Vary", "Origin")
+		}
+		if policy.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			c.Header("Access-Control-Allow-Methods", strings.Join(policy.AllowedMethods, ", "))
+			c.Header("Access-Control-Allow-Headers", strings.Join(policy.AllowedHeaders, ", "))
+			if policy.MaxAge > 0 {
+				c.Header("Access-Control-Max-Age", strconv.Itoa(policy.MaxAge))
+			}
+			c.AbortWithStatus(204)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// originAllowed reports whether origin may access the resource and whether it
+// matched through a "*" entry
+func originAllowed(policy *config.CORSPolicy, origin, host string) (bool, bool) {
+	// Same-origin is always allowed
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, host) {
+		return true, false
+	}
+
+	for _, o := range policy.AllowedOrigins {
+		if o == "*" {
+			return true, true
+		}
+		if strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true, false
+		}
+	}
+
+	return false, false
+}
+
+func hasPrefix(path string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,295 @@
+package middleware
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"antigravity-lite/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+const tokenUsageKey = "ratelimit.tokens"
+
+// SetTokenUsage reports the tokens consumed by a request so the rate limiter
+// can charge them against the tokens-per-minute buckets. Requests that don't
+// report usage are charged an estimate based on the request size.
+func SetTokenUsage(c *gin.Context, tokens int) {
+	c.Set(tokenUsageKey, tokens)
+}
+
+// bucket is a token bucket refilled continuously at limit per minute.
+// The level may go negative when actual usage exceeds what was admitted.
+type bucket struct {
+	level   float64
+	updated time.Time
+}
+
+// refill tops up the bucket and returns the current level
+func (b *bucket) refill(limit int, now time.Time) float64 {
+	if b.updated.IsZero() {
+		b.level = float64(limit)
+	} else {
+		b.level += now.Sub(b.updated).Seconds() * float64(limit) / 60
+		if b.level > float64(limit) {
+			b.level = float64(limit)
+		}
+	}
+	b.updated = now
+	return b.level
+}
+
+// waitFor returns how long until the bucket holds at least n
+func (b *bucket) waitFor(limit int, n float64) time.Duration {
+	if b.level >= n || limit <= 0 {
+		return 0
+	}
+	return time.Duration((n - b.level) / float64(limit) * 60 * float64(time.Second))
+}
+
+// limiterState holds the buckets and in-flight count of one client, IP or the whole proxy
+type limiterState struct {
+	requests bucket
+	tokens   bucket
+	inFlight int
+	lastSeen time.Time
+}
+
+// scope pairs a state with the rule that applies to it
+type scope struct {
+	state *limiterState
+	rule  config.RateLimitRule
+}
+
+// RateLimiter enforces per-key, per-IP and global ingress limits
+type RateLimiter struct {
+	cfg    *config.RateLimitConfig
+	mu     sync.Mutex
+	keys   map[string]*limiterState
+	ips    map[string]*limiterState
+	global *limiterState
+}
+
+// NewRateLimiter creates a rate limiter. The config is read on every request
+// so limits can be changed without a restart. Per-key limits apply to keys
+// authenticated by the ClientKeys middleware, which must run first; other
+// requests are limited per IP.
+func NewRateLimiter(cfg *config.RateLimitConfig) *RateLimiter {
+	rl := &RateLimiter{
+		cfg:    cfg,
+		keys:   make(map[string]*limiterState),
+		ips:    make(map[string]*limiterState),
+		global: &limiterState{},
+	}
+
+	go rl.periodicCleanup()
+
+	return rl
+}
+
+// periodicCleanup drops state for clients that have gone idle
+func (rl *RateLimiter) periodicCleanup() {
+	ticker := time.NewTicker(5 * time.Minute)
+	for range ticker.C {
+		rl.mu.Lock()
+		cutoff := time.Now().Add(-10 * time.Minute)
+		for k, s := range rl.keys {
+			if s.inFlight == 0 && s.lastSeen.Before(cutoff) {
+				delete(rl.keys, k)
+			}
+		}
+		for ip, s := range rl.ips {
+			if s.inFlight == 0 && s.lastSeen.Before(cutoff) {
+				delete(rl.ips, ip)
+			}
+		}
+		rl.mu.Unlock()
+	}
+}
+
+// Middleware applies the limits to requests whose path starts with one of the prefixes
+func (rl *RateLimiter) Middleware(prefixes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !rl.cfg.Enabled || c.Request.Method == "OPTIONS" || !hasPrefix(c.Request.URL.Path, prefixes) {
+			c.Next()
+			return
+		}
+
+		scopes := rl.acquire(c)
+		if scopes == nil {
+			return // rejected
+		}
+
+		defer rl.release(c, scopes)
+		c.Next()
+	}
+}
+
+// acquire admits the request against every scope or writes a 429 and returns nil
+func (rl *RateLimiter) acquire(c *gin.Context) []scope {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	scopes := []scope{{state: rl.global, rule: rl.cfg.Global}}
+	if ip := c.ClientIP(); ip != "" {
+		scopes = append(scopes, scope{state: stateFor(rl.ips, ip), rule: rl.cfg.PerIP})
+	}
+	// Only keys authenticated against client_keys get their own bucket;
+	// anything else a client sends would let it mint fresh buckets at will
+	if ck := AuthenticatedClientKey(c); ck != nil {
+		scopes = append(scopes, scope{state: stateFor(rl.keys, ck.Key), rule: rl.cfg.PerKey})
+	}
+
+	// Check every scope before consuming anything so a rejection is free
+	var retryAfter time.Duration
+	var rejected *scope
+	for i := range scopes {
+		s := &scopes[i]
+		s.state.lastSeen = now
+
+		var wait time.Duration
+		if s.rule.RequestsPerMinute > 0 {
+			s.state.requests.refill(s.rule.RequestsPerMinute, now)
+			wait = maxDuration(wait, s.state.requests.waitFor(s.rule.RequestsPerMinute, 1))
+		}
+		if s.rule.TokensPerMinute > 0 {
+			// Admit as long as the bucket is not in debt; actual usage is charged afterwards
+			s.state.tokens.refill(s.rule.TokensPerMinute, now)
+			if s.state.tokens.level <= 0 {
+				wait = maxDuration(wait, s.state.tokens.waitFor(s.rule.TokensPerMinute, 1))
+			}
+		}
+		if s.rule.MaxInFlight > 0 && s.state.inFlight >= s.rule.MaxInFlight {
+			wait = maxDuration(wait, time.Second)
+		}
+
+		if wait > retryAfter {
+			retryAfter = wait
+			rejected = s
+		}
+	}
+
+	if rejected != nil {
+		setRateLimitHeaders(c, *rejected)
+		writeRateLimited(c, retryAfter)
+		return nil
+	}
+
+	for i := range scopes {
+		if scopes[i].rule.RequestsPerMinute > 0 {
+			scopes[i].state.requests.level--
+		}
+		scopes[i].state.inFlight++
+	}
+
+	// Report the most specific scope to the client
+	setRateLimitHeaders(c, scopes[len(scopes)-1])
+
+	return scopes
+}
+
+// release ends the request and charges its token usage
+func (rl *RateLimiter) release(c *gin.Context, scopes []scope) {
+	tokens := c.GetInt(tokenUsageKey)
+	if _, reported := c.Get(tokenUsageKey); !reported && c.Request.ContentLength > 0 {
+		// Rough estimate: ~4 bytes per token
+		tokens = int(c.Request.ContentLength / 4)
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	for _, s := range scopes {
+		s.state.inFlight--
+		if s.rule.TokensPerMinute > 0 {
+			s.state.tokens.refill(s.rule.TokensPerMinute, now)
+			s.state.tokens.level -= float64(tokens)
+		}
+	}
+}
+
+func stateFor(m map[string]*limiterState, key string) *limiterState {
+	s, ok := m[key]
+	if !ok {
+		s = &limiterState{}
+		m[key] = s
+	}
+	return s
+}
+
+// ClientKey extracts the API key the client authenticated with, if any
+func ClientKey(c *gin.Context) string {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	if key := c.GetHeader("x-goog-api-key"); key != "" {
+		return key
+	}
+	if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return c.Query("key")
+}
+
+// setRateLimitHeaders writes OpenAI-style x-ratelimit-* headers for a scope
+func setRateLimitHeaders(c *gin.Context, s scope) {
+	if limit := s.rule.RequestsPerMinute; limit > 0 {
+		c.Header("x-ratelimit-limit-requests", strconv.Itoa(limit))
+		c.Header("x-ratelimit-remaining-requests", strconv.Itoa(int(math.Max(0, s.state.requests.level))))
+		c.Header("x-ratelimit-reset-requests", formatReset(s.state.requests.waitFor(limit, float64(limit))))
+	}
+	if limit := s.rule.TokensPerMinute; limit > 0 {
+		c.Header("x-ratelimit-limit-tokens", strconv.Itoa(limit))
+		c.Header("x-ratelimit-remaining-tokens", strconv.Itoa(int(math.Max(0, s.state.tokens.level))))
+		c.Header("x-ratelimit-reset-tokens", formatReset(s.state.tokens.waitFor(limit, float64(limit))))
+	}
+}
+
+// writeRateLimited writes a 429 in the error format of the protocol being used
+func writeRateLimited(c *gin.Context, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	writeError(c, 429, "Rate limit exceeded, retry after "+strconv.Itoa(seconds)+"s")
+}
+
+// writeError aborts with a 401 or 429 in the error format of the protocol
+// being used
+func writeError(c *gin.Context, status int, msg string) {
+	anthropicType, googleStatus, openaiType, openaiCode := "rate_limit_error", "RESOURCE_EXHAUSTED", "rate_limit_error", "rate_limit_exceeded"
+	if status == 401 {
+		anthropicType, googleStatus, openaiType, openaiCode = "authentication_error", "UNAUTHENTICATED", "invalid_request_error", "invalid_api_key"
+	}
+
+	path := c.Request.URL.Path
+	switch {
+	case strings.HasPrefix(path, "/v1/messages"):
+		c.AbortWithStatusJSON(status, gin.H{"type": "error", "error": gin.H{"type": anthropicType, "message": msg}})
+	case strings.HasPrefix(path, "/v1beta"):
+		c.AbortWithStatusJSON(status, gin.H{"error": gin.H{"code": status, "message": msg, "status": googleStatus}})
+	default:
+		c.AbortWithStatusJSON(status, gin.H{"error": gin.H{"message": msg, "type": openaiType, "code": openaiCode}})
+	}
+}
+
+// formatReset formats a duration the way OpenAI does in x-ratelimit-reset-* ("1s", "6m0s")
+func formatReset(d time.Duration) string {
+	if d <= 0 {
+		return "0s"
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,212 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"antigravity-lite/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestBucket(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		level    float64 // level after the first refill, before elapsed
+		elapsed  time.Duration
+		limit    int
+		want     float64
+		wantWait time.Duration // until the bucket holds 1
+	}{
+		{"starts full", 60, 0, 60, 60, 0},
+		{"refills at limit per minute", 0, 30 * time.Second, 60, 30, 0},
+		{"caps at the limit", 50, time.Minute, 60, 60, 0},
+		{"empty bucket waits for one token", 0, 0, 60, 0, time.Second},
+		{"debt is paid off before admitting", -119, 0, 60, -119, 2 * time.Minute},
+	}
+	for _, tt := range tests {
+		var b bucket
+		b.refill(tt.limit, start)
+		b.level = tt.level
+		if got := b.refill(tt.limit, start.Add(tt.elapsed)); got != tt.want {
+			t.Errorf("%s: level = %v, want %v", tt.name, got, tt.want)
+		}
+		if got := b.waitFor(tt.limit, 1); got != tt.wantWait {
+			t.Errorf("%s: waitFor = %v, want %v", tt.name, got, tt.wantWait)
+		}
+	}
+}
+
+// newLimitedRouter serves 200 on the proxy paths behind the client key and
+// rate limit middleware. tokens is reported as each request's usage.
+func newLimitedRouter(cfg *config.Config, tokens int) *gin.Engine {
+	r := gin.New()
+	r.Use(ClientKeys(cfg, "/v1", "/v1beta"))
+	r.Use(NewRateLimiter(&cfg.RateLimit).Middleware("/v1", "/v1beta"))
+	ok := func(c *gin.Context) {
+		if tokens > 0 {
+			SetTokenUsage(c, tokens)
+		}
+		c.JSON(200, gin.H{})
+	}
+	r.POST("/v1/chat/completions", ok)
+	r.POST("/v1/messages", ok)
+	r.POST("/v1beta/models/m:generateContent", ok)
+	return r
+}
+
+func serve(r *gin.Engine, path, key string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", path, nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequestsPerMinute(t *testing.T) {
+	cfg := &config.Config{RateLimit: config.RateLimitConfig{
+		Enabled: true,
+		PerIP:   config.RateLimitRule{RequestsPerMinute: 2},
+	}}
+	r := newLimitedRouter(cfg, 0)
+
+	steps := []struct {
+		wantCode      int
+		wantRemaining string
+		wantRetry     string
+	}{
+		{200, "1", ""},
+		{200, "0", ""},
+		{429, "0", "30"},
+	}
+	for i, step := range steps {
+		w := serve(r, "/v1/chat/completions", "")
+		if w.Code != step.wantCode {
+			t.Fatalf("request %d: status %d, want %d", i+1, w.Code, step.wantCode)
+		}
+		if got := w.Header().Get("x-ratelimit-limit-requests"); got != "2" {
+			t.Errorf("request %d: x-ratelimit-limit-requests = %q, want 2", i+1, got)
+		}
+		if got := w.Header().Get("x-ratelimit-remaining-requests"); got != step.wantRemaining {
+			t.Errorf("request %d: x-ratelimit-remaining-requests = %q, want %q", i+1, got, step.wantRemaining)
+		}
+		if got := w.Header().Get("Retry-After"); got != step.wantRetry {
+			t.Errorf("request %d: Retry-After = %q, want %q", i+1, got, step.wantRetry)
+		}
+	}
+}
+
+func TestRateLimitedErrorFormat(t *testing.T) {
+	tests := []struct {
+		path string
+		want string // error type or status in the protocol's format
+		get  func(body map[string]interface{}) interface{}
+	}{
+		{"/v1/chat/completions", "rate_limit_error", func(b map[string]interface{}) interface{} {
+			return b["error"].(map[string]interface{})["type"]
+		}},
+		{"/v1/messages", "rate_limit_error", func(b map[string]interface{}) interface{} {
+			return b["error"].(map[string]interface{})["type"]
+		}},
+		{"/v1beta/models/m:generateContent", "RESOURCE_EXHAUSTED", func(b map[string]interface{}) interface{} {
+			return b["error"].(map[string]interface{})["status"]
+		}},
+	}
+	for _, tt := range tests {
+		cfg := &config.Config{RateLimit: config.RateLimitConfig{
+			Enabled: true,
+			Global:  config.RateLimitRule{RequestsPerMinute: 1},
+		}}
+		r := newLimitedRouter(cfg, 0)
+		serve(r, tt.path, "")
+		w := serve(r, tt.path, "")
+		if w.Code != 429 {
+			t.Fatalf("%s: status %d, want 429", tt.path, w.Code)
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: %v", tt.path, err)
+		}
+		if got := tt.get(body); got != tt.want {
+			t.Errorf("%s: error = %v, want %v (%s)", tt.path, got, tt.want, w.Body)
+		}
+	}
+}
+
+func TestTokensPerMinuteChargesUsage(t *testing.T) {
+	cfg := &config.Config{RateLimit: config.RateLimitConfig{
+		Enabled: true,
+		PerIP:   config.RateLimitRule{TokensPerMinute: 600},
+	}}
+	r := newLimitedRouter(cfg, 660) // leaves the bucket 60 tokens in debt
+
+	if w := serve(r, "/v1/chat/completions", ""); w.Code != 200 {
+		t.Fatalf("first request: status %d, want 200", w.Code)
+	}
+	w := serve(r, "/v1/chat/completions", "")
+	if w.Code != 429 {
+		t.Fatalf("second request: status %d, want 429", w.Code)
+	}
+	// 61 tokens at 600 per minute
+	if got := w.Header().Get("Retry-After"); got != "7" {
+		t.Errorf("Retry-After = %q, want 7", got)
+	}
+	if got := w.Header().Get("x-ratelimit-remaining-tokens"); got != "0" {
+		t.Errorf("x-ratelimit-remaining-tokens = %q, want 0", got)
+	}
+}
+
+func TestPerKeyLimits(t *testing.T) {
+	tests := []struct {
+		name       string
+		clientKeys []config.ClientKeyConfig
+		keys       []string
+		wantCodes  []int
+	}{
+		{
+			// Made-up keys fall back to the per-IP limit of 2
+			name:      "unlisted keys share the IP bucket",
+			keys:      []string{"random-1", "random-2", "random-3"},
+			wantCodes: []int{200, 200, 429},
+		},
+		{
+			name:       "listed key has its own bucket",
+			clientKeys: []config.ClientKeyConfig{{Name: "a", Key: "key-a"}, {Name: "b", Key: "key-b"}},
+			keys:       []string{"key-a", "key-a", "key-b"},
+			wantCodes:  []int{200, 429, 200},
+		},
+		{
+			name:       "unknown key is rejected once keys are listed",
+			clientKeys: []config.ClientKeyConfig{{Name: "a", Key: "key-a"}},
+			keys:       []string{"key-z", ""},
+			wantCodes:  []int{401, 401},
+		},
+	}
+	for _, tt := range tests {
+		cfg := &config.Config{
+			ClientKeys: tt.clientKeys,
+			RateLimit: config.RateLimitConfig{
+				Enabled: true,
+				PerKey:  config.RateLimitRule{RequestsPerMinute: 1},
+				PerIP:   config.RateLimitRule{RequestsPerMinute: 2},
+			},
+		}
+		r := newLimitedRouter(cfg, 0)
+		for i, key := range tt.keys {
+			if w := serve(r, "/v1/chat/completions", key); w.Code != tt.wantCodes[i] {
+				t.Errorf("%s: request %d with %q: status %d, want %d", tt.name, i+1, key, w.Code, tt.wantCodes[i])
+			}
+		}
+	}
+}
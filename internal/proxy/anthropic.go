@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"antigravity-lite/internal/account"
+	"antigravity-lite/internal/middleware"
 
 	"github.com/gin-gonic/gin"
 )
@@ -23,6 +24,12 @@ type AnthropicRequest struct {
 	MaxTokens   int                      `json:"max_tokens"`
 	Stream      bool                     `json:"stream"`
 	Temperature float64                  `json:"temperature,omitempty"`
+	Metadata    *AnthropicMetadata       `json:"metadata,omitempty"`
+}
+
+// AnthropicMetadata carries request metadata such as the end-user ID
+type AnthropicMetadata struct {
+	UserID string `json:"user_id,omitempty"`
 }
 
 // AnthropicResponse represents Anthropic API response
@@ -59,38 +66,56 @@ func (h *Handler) HandleAnthropicMessages(c *gin.Context) {
 		targetModel = h.router.GetLightModel()
 	}
 
-	// Get account
-	acct, err := h.accountMgr.GetNextActive()
+	// Get account (sticky per conversation, restricted to the allowed pools)
+	userID := ""
+	if req.Metadata != nil {
+		userID = req.Metadata.UserID
+	}
+	sessionID := sessionKey(c, userID, req.Messages)
+	pools := h.poolFilter(c, req.Model)
+	acct, err := h.accountMgr.GetNextActiveWithSession(c.Request.Context(), sessionID, targetModel, pools)
 	if err != nil {
+		if acquireStatus(c, err) == 429 {
+			c.JSON(429, gin.H{"type": "error", "error": gin.H{"type": "rate_limit_error", "message": err.Error()}})
+			return
+		}
 		c.JSON(503, gin.H{"type": "error", "error": gin.H{"type": "overloaded_error", "message": "no available accounts"}})
 		return
 	}
 
 	// Ensure valid token
 	if err := h.accountMgr.EnsureValidToken(acct); err != nil {
+		h.accountMgr.Release(acct.ID)
 		c.JSON(503, gin.H{"type": "error", "error": gin.H{"type": "authentication_error", "message": "token refresh failed"}})
 		return
 	}
 
+	// The handlers take over the account's concurrency slot
 	if req.Stream {
 		h.handleAnthropicStream(c, acct, targetModel, req)
 	} else {
-		h.handleAnthropicNonStream(c, acct, targetModel, req)
+		h.handleAnthropicNonStream(c, acct, targetModel, req, sessionID, pools)
 	}
 }
 
 // handleAnthropicNonStream handles non-streaming Anthropic requests
-func (h *Handler) handleAnthropicNonStream(c *gin.Context, acct *account.Account, model string, req AnthropicRequest) {
+func (h *Handler) handleAnthropicNonStream(c *gin.Context, acct *account.Account, model string, req AnthropicRequest, sessionID string, pools account.PoolFilter) {
+	defer func() {
+		if acct != nil {
+			h.accountMgr.Release(acct.ID)
+		}
+	}()
 	start := time.Now()
 
 	resp, statusCode, err := h.callGeminiForAnthropic(acct, model, req)
 	if err != nil {
+		h.accountMgr.MarkAccountError(acct.ID, model, statusCode, err)
+
 		// Retry with rotation
 		if h.cfg.Proxy.AutoRotate && (statusCode == 429 || statusCode == 401 || statusCode == 403) {
-			h.accountMgr.MarkAccountError(acct.ID, statusCode)
-
 			for i := 0; i < h.cfg.Proxy.MaxRetries; i++ {
-				acct, err = h.accountMgr.GetNextActive()
+				h.accountMgr.Release(acct.ID)
+				acct, err = h.accountMgr.GetNextActiveWithSession(c.Request.Context(), sessionID, model, pools)
 				if err != nil {
 					break
 				}
@@ -101,24 +126,34 @@ func (h *Handler) handleAnthropicNonStream(c *gin.Context, acct *account.Account
 				if err == nil {
 					break
 				}
-				h.accountMgr.MarkAccountError(acct.ID, statusCode)
+				h.accountMgr.MarkAccountError(acct.ID, model, statusCode, err)
 			}
 		}
 
 		if err != nil {
-			c.JSON(statusCode, gin.H{"type": "error", "error": gin.H{"type": "api_error", "message": err.Error()}})
+			if acct == nil {
+				statusCode = acquireStatus(c, err)
+			}
+			msg := h.logFailure(acct, model, req.Model, start, statusCode, err)
+			c.JSON(statusCode, gin.H{"type": "error", "error": gin.H{"type": "api_error", "message": msg}})
 			return
 		}
 	}
 
 	latency := time.Since(start).Milliseconds()
+	h.accountMgr.MarkAccountSuccess(acct.ID, model, time.Since(start))
 
 	// Log request
-	_ = h.accountMgr.GetStorage().LogRequest(
-		acct.ID, model,
-		resp.Usage.InputTokens, resp.Usage.OutputTokens,
-		int(latency), statusCode,
-	)
+	_ = h.accountMgr.LogRequest(account.RequestLog{
+		AccountID:      acct.ID,
+		Model:          model,
+		RequestedModel: req.Model,
+		TokensIn:       resp.Usage.InputTokens,
+		TokensOut:      resp.Usage.OutputTokens,
+		LatencyMs:      int(latency),
+		StatusCode:     statusCode,
+	})
+	middleware.SetTokenUsage(c, resp.Usage.InputTokens+resp.Usage.OutputTokens)
 
 	c.JSON(200, resp)
 }
@@ -152,7 +187,7 @@ func (h *Handler) callGeminiForAnthropic(acct *account.Account, model string, re
 	body, _ := io.ReadAll(resp.Body)
 
 	if resp.StatusCode != 200 {
-		return nil, resp.StatusCode, fmt.Errorf("API error: %s", string(body))
+		return nil, resp.StatusCode, account.NewUpstreamError(resp, body)
 	}
 
 	// Parse Gemini response
@@ -215,6 +250,9 @@ func (h *Handler) callGeminiForAnthropic(acct *account.Account, model string, re
 
 // handleAnthropicStream handles streaming Anthropic requests
 func (h *Handler) handleAnthropicStream(c *gin.Context, acct *account.Account, model string, req AnthropicRequest) {
+	defer h.accountMgr.Release(acct.ID)
+	start := time.Now()
+
 	// Convert to OpenAI format first, then to Gemini
 	messages := req.Messages
 	if req.System != "" {
@@ -235,17 +273,25 @@ func (h *Handler) handleAnthropicStream(c *gin.Context, acct *account.Account, m
 
 	resp, err := h.client.Do(httpReq)
 	if err != nil {
-		c.JSON(500, gin.H{"type": "error", "error": gin.H{"type": "api_error", "message": err.Error()}})
+		h.accountMgr.MarkAccountError(acct.ID, model, 500, err)
+		msg := h.logFailure(acct, model, req.Model, start, 500, err)
+		c.JSON(500, gin.H{"type": "error", "error": gin.H{"type": "api_error", "message": msg}})
 		return
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
 		body, _ := io.ReadAll(resp.Body)
-		c.JSON(resp.StatusCode, gin.H{"type": "error", "error": gin.H{"type": "api_error", "message": string(body)}})
+		upstreamErr := account.NewUpstreamError(resp, body)
+		h.accountMgr.MarkAccountError(acct.ID, model, resp.StatusCode, upstreamErr)
+		msg := h.logFailure(acct, model, req.Model, start, resp.StatusCode, upstreamErr)
+		c.JSON(resp.StatusCode, gin.H{"type": "error", "error": gin.H{"type": "api_error", "message": msg}})
 		return
 	}
 
+	// Time to first byte is what matters for performance scheduling
+	h.accountMgr.MarkAccountSuccess(acct.ID, model, time.Since(start))
+
 	// Set headers for SSE
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
@@ -281,7 +327,10 @@ func (h *Handler) handleAnthropicStream(c *gin.Context, acct *account.Account, m
 	c.Writer.Write([]byte("event: content_block_start\ndata: " + string(blockStartJSON) + "\n\n"))
 	c.Writer.Flush()
 
-	// Stream response
+	// Stream response. Usage metadata is cumulative, so the last one seen
+	// holds the totals.
+	var tokensIn, tokensOut int
+	var ttft time.Duration
 	scanner := bufio.NewScanner(resp.Body)
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -302,14 +351,25 @@ func (h *Handler) handleAnthropicStream(c *gin.Context, acct *account.Account, m
 					} `json:"parts"`
 				} `json:"content"`
 			} `json:"candidates"`
+			UsageMetadata struct {
+				PromptTokenCount     int `json:"promptTokenCount"`
+				CandidatesTokenCount int `json:"candidatesTokenCount"`
+			} `json:"usageMetadata"`
 		}
 
 		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
 			continue
 		}
+		if chunk.UsageMetadata.PromptTokenCount > 0 || chunk.UsageMetadata.CandidatesTokenCount > 0 {
+			tokensIn = chunk.UsageMetadata.PromptTokenCount
+			tokensOut = chunk.UsageMetadata.CandidatesTokenCount
+		}
 
 		if len(chunk.Candidates) > 0 && len(chunk.Candidates[0].Content.Parts) > 0 {
 			text := chunk.Candidates[0].Content.Parts[0].Text
+			if ttft == 0 {
+				ttft = time.Since(start)
+			}
 
 			// Send content_block_delta
 			deltaEvent := map[string]interface{}{
@@ -341,7 +401,7 @@ func (h *Handler) handleAnthropicStream(c *gin.Context, acct *account.Account, m
 		"delta": map[string]interface{}{
 			"stop_reason": "end_turn",
 		},
-		"usage": map[string]int{"output_tokens": 0},
+		"usage": map[string]int{"output_tokens": tokensOut},
 	}
 	msgDeltaJSON, _ := json.Marshal(msgDeltaEvent)
 	c.Writer.Write([]byte("event: message_delta\ndata: " + string(msgDeltaJSON) + "\n\n"))
@@ -354,4 +414,18 @@ func (h *Handler) handleAnthropicStream(c *gin.Context, acct *account.Account, m
 	msgStopJSON, _ := json.Marshal(msgStopEvent)
 	c.Writer.Write([]byte("event: message_stop\ndata: " + string(msgStopJSON) + "\n\n"))
 	c.Writer.Flush()
+
+	_ = h.accountMgr.LogRequest(account.RequestLog{
+		AccountID:      acct.ID,
+		Model:          model,
+		RequestedModel: req.Model,
+		TokensIn:       tokensIn,
+		TokensOut:      tokensOut,
+		LatencyMs:      int(time.Since(start).Milliseconds()),
+		TTFTMs:         int(ttft.Milliseconds()),
+		StatusCode:     200,
+	})
+	if tokensIn+tokensOut > 0 {
+		middleware.SetTokenUsage(c, tokensIn+tokensOut)
+	}
 }
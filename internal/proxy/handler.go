@@ -2,15 +2,21 @@ package proxy
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"antigravity-lite/config"
 	"antigravity-lite/internal/account"
+	"antigravity-lite/internal/middleware"
+	"antigravity-lite/internal/redact"
 	"antigravity-lite/internal/router"
 
 	"github.com/gin-gonic/gin"
@@ -43,6 +49,7 @@ type ChatCompletionRequest struct {
 	Stream      bool                     `json:"stream"`
 	Temperature float64                  `json:"temperature,omitempty"`
 	MaxTokens   int                      `json:"max_tokens,omitempty"`
+	User        string                   `json:"user,omitempty"`
 }
 
 type ChatCompletionResponse struct {
@@ -79,12 +86,24 @@ func (h *Handler) HandleChatCompletions(c *gin.Context) {
 		targetModel = h.router.GetLightModel()
 	}
 
-	// Get account
-	acct, err := h.accountMgr.GetNextActive()
+	// Get account (sticky per conversation, restricted to the allowed pools)
+	sessionID := sessionKey(c, req.User, req.Messages)
+	pools := h.poolFilter(c, originalModel)
+	acct, err := h.accountMgr.GetNextActiveWithSession(c.Request.Context(), sessionID, targetModel, pools)
 	if err != nil {
+		if acquireStatus(c, err) == 429 {
+			c.JSON(429, gin.H{"error": gin.H{"message": err.Error(), "type": "rate_limit_error", "code": "rate_limit_exceeded"}})
+			return
+		}
 		c.JSON(503, gin.H{"error": gin.H{"message": "no available accounts", "type": "service_unavailable"}})
 		return
 	}
+	// Free the concurrency slot of whichever account ends up serving the request
+	defer func() {
+		if acct != nil {
+			h.accountMgr.Release(acct.ID)
+		}
+	}()
 
 	// Ensure valid token
 	if err := h.accountMgr.EnsureValidToken(acct); err != nil {
@@ -97,12 +116,13 @@ func (h *Handler) HandleChatCompletions(c *gin.Context) {
 	// Convert to Gemini format and call API
 	resp, statusCode, err := h.callGeminiAPI(acct, targetModel, req)
 	if err != nil {
+		h.accountMgr.MarkAccountError(acct.ID, targetModel, statusCode, err)
+
 		// Try with another account on error
 		if h.cfg.Proxy.AutoRotate && (statusCode == 429 || statusCode == 401 || statusCode == 403) {
-			h.accountMgr.MarkAccountError(acct.ID, statusCode)
-
 			for i := 0; i < h.cfg.Proxy.MaxRetries; i++ {
-				acct, err = h.accountMgr.GetNextActive()
+				h.accountMgr.Release(acct.ID)
+				acct, err = h.accountMgr.GetNextActiveWithSession(c.Request.Context(), sessionID, targetModel, pools)
 				if err != nil {
 					break
 				}
@@ -113,29 +133,79 @@ func (h *Handler) HandleChatCompletions(c *gin.Context) {
 				if err == nil {
 					break
 				}
-				h.accountMgr.MarkAccountError(acct.ID, statusCode)
+				h.accountMgr.MarkAccountError(acct.ID, targetModel, statusCode, err)
 			}
 		}
 
 		if err != nil {
-			c.JSON(statusCode, gin.H{"error": gin.H{"message": err.Error(), "type": "api_error"}})
+			if acct == nil {
+				statusCode = acquireStatus(c, err)
+			}
+			msg := h.logFailure(acct, targetModel, originalModel, start, statusCode, err)
+			c.JSON(statusCode, gin.H{"error": gin.H{"message": msg, "type": "api_error"}})
 			return
 		}
 	}
 
 	latency := time.Since(start).Milliseconds()
+	h.accountMgr.MarkAccountSuccess(acct.ID, targetModel, time.Since(start))
 
 	// Log request
-	_ = h.accountMgr.GetStorage().LogRequest(
-		acct.ID, targetModel,
-		resp.Usage.PromptTokens, resp.Usage.CompletionTokens,
-		int(latency), statusCode,
-	)
+	_ = h.accountMgr.LogRequest(account.RequestLog{
+		AccountID:      acct.ID,
+		Model:          targetModel,
+		RequestedModel: originalModel,
+		TokensIn:       resp.Usage.PromptTokens,
+		TokensOut:      resp.Usage.CompletionTokens,
+		LatencyMs:      int(latency),
+		StatusCode:     statusCode,
+	})
+	middleware.SetTokenUsage(c, resp.Usage.PromptTokens+resp.Usage.CompletionTokens)
 
 	// Return OpenAI format response
 	c.JSON(200, resp)
 }
 
+// acquireStatus maps an account acquisition error to an HTTP status code,
+// setting Retry-After when every account is rate limited
+func acquireStatus(c *gin.Context, err error) int {
+	var rle *account.RateLimitedError
+	switch {
+	case errors.As(err, &rle):
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(rle.RetryAfter.Seconds()))))
+		return 429
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return 499 // client went away while waiting
+	default:
+		return 503
+	}
+}
+
+// logFailure records the full upstream error in the request log for admins
+// and returns a redacted message that is safe to send to the client
+func (h *Handler) logFailure(acct *account.Account, model, requestedModel string, start time.Time, statusCode int, err error) string {
+	var accountID int64
+	if acct != nil {
+		accountID = acct.ID
+	}
+	detail := err.Error()
+	var ue *account.UpstreamError
+	if errors.As(err, &ue) {
+		detail = ue.Detail()
+	}
+	latency := int(time.Since(start).Milliseconds())
+	_ = h.accountMgr.LogRequest(account.RequestLog{
+		AccountID:      accountID,
+		Model:          model,
+		RequestedModel: requestedModel,
+		LatencyMs:      latency,
+		StatusCode:     statusCode,
+		Error:          detail,
+	})
+
+	return redact.String(detail)
+}
+
 // callGeminiAPI calls Gemini API with converted request
 func (h *Handler) callGeminiAPI(acct *account.Account, model string, req ChatCompletionRequest) (*ChatCompletionResponse, int, error) {
 	// Convert messages to Gemini format
@@ -158,7 +228,7 @@ func (h *Handler) callGeminiAPI(acct *account.Account, model string, req ChatCom
 	body, _ := io.ReadAll(resp.Body)
 
 	if resp.StatusCode != 200 {
-		return nil, resp.StatusCode, fmt.Errorf("API error: %s", string(body))
+		return nil, resp.StatusCode, account.NewUpstreamError(resp, body)
 	}
 
 	// Parse Gemini response
@@ -439,6 +509,7 @@ func (h *Handler) FetchLiveModels(c *gin.Context) {
 		h.HandleGeminiModels(c)
 		return
 	}
+	defer h.accountMgr.Release(acct.ID)
 
 	// Ensure valid token
 	if err := h.accountMgr.EnsureValidToken(acct); err != nil {
@@ -453,7 +524,7 @@ func (h *Handler) FetchLiveModels(c *gin.Context) {
 
 	resp, err := h.client.Do(httpReq)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch models: " + err.Error()})
+		c.JSON(500, gin.H{"error": "Failed to fetch models: " + redact.Error(err)})
 		return
 	}
 	defer resp.Body.Close()
@@ -461,7 +532,7 @@ func (h *Handler) FetchLiveModels(c *gin.Context) {
 	body, _ := io.ReadAll(resp.Body)
 
 	if resp.StatusCode != 200 {
-		c.JSON(resp.StatusCode, gin.H{"error": "API error: " + string(body)})
+		c.JSON(resp.StatusCode, gin.H{"error": "API error: " + redact.String(string(body))})
 		return
 	}
 
@@ -489,7 +560,7 @@ func (h *Handler) FetchLiveModels(c *gin.Context) {
 			}
 		}
 		modelsResp["models"] = filteredModels
-		modelsResp["account_used"] = acct.Email
+		modelsResp["account_id"] = acct.ID
 	}
 
 	c.JSON(200, modelsResp)
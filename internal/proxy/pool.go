@@ -0,0 +1,24 @@
+package proxy
+
+import (
+	"antigravity-lite/internal/account"
+	"antigravity-lite/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// poolFilter collects the account pool restrictions for a request: those of
+// the route matching the requested model and those of the client's API key
+func (h *Handler) poolFilter(c *gin.Context, requestedModel string) account.PoolFilter {
+	var filter account.PoolFilter
+
+	if pools := h.router.Pools(requestedModel); len(pools) > 0 {
+		filter = append(filter, pools)
+	}
+
+	if ck := middleware.AuthenticatedClientKey(c); ck != nil && len(ck.Pools) > 0 {
+		filter = append(filter, ck.Pools)
+	}
+
+	return filter
+}
@@ -0,0 +1,51 @@
+package proxy
+
+import (
+	"strings"
+
+	"antigravity-lite/internal/account"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionHeader lets clients pin a conversation to an account explicitly
+const SessionHeader = "X-Session-ID"
+
+// sessionKey derives a stable session ID for account stickiness, in order of preference:
+// the X-Session-ID header, a client-supplied user ID (Anthropic metadata.user_id
+// or OpenAI user), then a hash of the first user message
+func sessionKey(c *gin.Context, userID string, messages []map[string]interface{}) string {
+	if id := strings.TrimSpace(c.GetHeader(SessionHeader)); id != "" {
+		return account.GenerateSessionID("header:" + id)
+	}
+	if userID != "" {
+		return account.GenerateSessionID("user:" + userID)
+	}
+	return account.GenerateSessionID(firstUserMessage(messages))
+}
+
+// firstUserMessage returns the text of the first user message
+func firstUserMessage(messages []map[string]interface{}) string {
+	for _, msg := range messages {
+		if role, _ := msg["role"].(string); role != "user" {
+			continue
+		}
+
+		switch content := msg["content"].(type) {
+		case string:
+			return content
+		case []interface{}:
+			var sb strings.Builder
+			for _, part := range content {
+				if p, ok := part.(map[string]interface{}); ok {
+					if text, ok := p["text"].(string); ok {
+						sb.WriteString(text)
+					}
+				}
+			}
+			return sb.String()
+		}
+		return ""
+	}
+	return ""
+}
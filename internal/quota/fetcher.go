@@ -46,9 +46,10 @@ type Tier struct {
 
 // ModelQuota represents quota for a single model
 type ModelQuota struct {
-	Name       string `json:"name"`
-	Percentage int    `json:"percentage"`
-	ResetTime  string `json:"reset_time,omitempty"`
+	Name              string  `json:"name"`
+	Percentage        int     `json:"percentage"`
+	RemainingFraction float64 `json:"remaining_fraction"`
+	ResetTime         string  `json:"reset_time,omitempty"`
 }
 
 // AccountQuota represents full quota data for an account
@@ -187,16 +188,19 @@ func (f *QuotaFetcher) FetchQuota(accessToken, projectID, email string) (*Accoun
 		}
 
 		percentage := 0
+		fraction := 0.0
 		resetTime := ""
 		if info.QuotaInfo != nil {
-			percentage = int(info.QuotaInfo.RemainingFraction * 100)
+			fraction = info.QuotaInfo.RemainingFraction
+			percentage = int(fraction * 100)
 			resetTime = info.QuotaInfo.ResetTime
 		}
 
 		accountQuota.Models = append(accountQuota.Models, ModelQuota{
-			Name:       name,
-			Percentage: percentage,
-			ResetTime:  resetTime,
+			Name:              name,
+			Percentage:        percentage,
+			RemainingFraction: fraction,
+			ResetTime:         resetTime,
 		})
 	}
 
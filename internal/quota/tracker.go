@@ -2,17 +2,93 @@ package quota
 
 import (
 	"database/sql"
+	"math"
+	"sort"
+	"strconv"
 	"time"
+
+	"antigravity-lite/config"
+)
+
+// Time series granularities
+const (
+	GranularityMinute = "minute"
+	GranularityHour   = "hour"
+	GranularityDay    = "day"
 )
 
+// Model stats groupings
+const (
+	ByRouted    = "routed"    // the model sent upstream
+	ByRequested = "requested" // the model the client asked for
+	ByRoute     = "route"     // each requested -> routed pair
+)
+
+// DefaultStatsWindow is how far back stats go when a query has no start;
+// the time series default to the last day
+const DefaultStatsWindow = 7 * 24 * time.Hour
+
+// maxPercentileSamples caps the requests per group the percentiles are
+// computed from; larger groups use their most recent requests
+const maxPercentileSamples = 5000
+
+// requestedModelExpr falls back to the routed model for requests logged
+// before the requested model was recorded
+const requestedModelExpr = "COALESCE(NULLIF(requested_model, ''), model)"
+
 // Tracker tracks quota and usage statistics
 type Tracker struct {
-	db *sql.DB
+	db  *sql.DB
+	cfg *config.Config
+}
+
+// NewTracker creates a new quota tracker. Days are counted in the
+// configured timezone.
+func NewTracker(db *sql.DB, cfg *config.Config) *Tracker {
+	return &Tracker{db: db, cfg: cfg}
+}
+
+// StatsRange selects the requests a stats query covers; a zero From or To
+// leaves that end open
+type StatsRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// since returns the range with a start window before now when it has none
+func (r StatsRange) since(window time.Duration) StatsRange {
+	if r.From.IsZero() {
+		r.From = time.Now().Add(-window)
+	}
+	return r
+}
+
+// where returns the condition and arguments restricting a time column to
+// the range
+func (r StatsRange) where(column string) (string, []interface{}) {
+	cond := "1 = 1"
+	var args []interface{}
+	if !r.From.IsZero() {
+		cond += " AND " + column + " >= ?"
+		args = append(args, dbTime(r.From))
+	}
+	if !r.To.IsZero() {
+		cond += " AND " + column + " < ?"
+		args = append(args, dbTime(r.To))
+	}
+	return cond, args
+}
+
+// dbTime formats a time like SQLite's CURRENT_TIMESTAMP so it compares as text
+func dbTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
 }
 
-// NewTracker creates a new quota tracker
-func NewTracker(db *sql.DB) *Tracker {
-	return &Tracker{db: db}
+// Percentiles summarizes a distribution in milliseconds
+type Percentiles struct {
+	P50 int64 `json:"p50"`
+	P95 int64 `json:"p95"`
+	P99 int64 `json:"p99"`
 }
 
 // Stats represents usage statistics
@@ -25,37 +101,54 @@ type Stats struct {
 	RequestsToday     int64   `json:"requests_today"`
 	RequestsThisWeek  int64   `json:"requests_this_week"`
 	RequestsThisMonth int64   `json:"requests_this_month"`
+
+	// Latency and time to first token of successful requests
+	Latency        Percentiles      `json:"latency_ms"`
+	TTFT           Percentiles      `json:"ttft_ms"`
+	ErrorsByStatus map[string]int64 `json:"errors_by_status"`
 }
 
-// ModelStats represents per-model statistics
+// ModelStats represents per-model statistics. Model is the routed model and
+// RequestedModel the one clients asked for; each is empty when the stats
+// are not grouped by it.
 type ModelStats struct {
-	Model        string  `json:"model"`
-	Requests     int64   `json:"requests"`
-	TokensIn     int64   `json:"tokens_in"`
-	TokensOut    int64   `json:"tokens_out"`
-	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	Model          string      `json:"model,omitempty"`
+	RequestedModel string      `json:"requested_model,omitempty"`
+	Requests       int64       `json:"requests"`
+	TokensIn       int64       `json:"tokens_in"`
+	TokensOut      int64       `json:"tokens_out"`
+	AvgLatencyMs   float64     `json:"avg_latency_ms"`
+	SuccessRate    float64     `json:"success_rate"`
+	Latency        Percentiles `json:"latency_ms"`
+	TTFT           Percentiles `json:"ttft_ms"`
 }
 
 // AccountStats represents per-account statistics
 type AccountStats struct {
-	AccountID   int64   `json:"account_id"`
-	AccountName string  `json:"account_name"`
-	Requests    int64   `json:"requests"`
-	TokensIn    int64   `json:"tokens_in"`
-	TokensOut   int64   `json:"tokens_out"`
-	SuccessRate float64 `json:"success_rate"`
+	AccountID   int64       `json:"account_id"`
+	AccountName string      `json:"account_name"`
+	Requests    int64       `json:"requests"`
+	TokensIn    int64       `json:"tokens_in"`
+	TokensOut   int64       `json:"tokens_out"`
+	SuccessRate float64     `json:"success_rate"`
+	Latency     Percentiles `json:"latency_ms"`
+	TTFT        Percentiles `json:"ttft_ms"`
 }
 
-// GetStats returns overall usage statistics
-func (t *Tracker) GetStats() (*Stats, error) {
+// GetStats returns usage statistics for the requests in the range, the
+// last DefaultStatsWindow without a start. The today, week and month counts
+// always end now.
+func (t *Tracker) GetStats(r StatsRange) (*Stats, error) {
 	var stats Stats
+	r = r.since(DefaultStatsWindow)
+	cond, args := r.where("created_at")
 
 	// Total requests
 	err := t.db.QueryRow(`
-		SELECT COUNT(*), COALESCE(SUM(tokens_in), 0), COALESCE(SUM(tokens_out), 0), 
+		SELECT COUNT(*), COALESCE(SUM(tokens_in), 0), COALESCE(SUM(tokens_out), 0),
 		       COALESCE(AVG(latency_ms), 0)
-		FROM request_logs
-	`).Scan(&stats.TotalRequests, &stats.TotalTokensIn, &stats.TotalTokensOut, &stats.AvgLatencyMs)
+		FROM request_logs WHERE `+cond, args...,
+	).Scan(&stats.TotalRequests, &stats.TotalTokensIn, &stats.TotalTokensOut, &stats.AvgLatencyMs)
 	if err != nil {
 		return nil, err
 	}
@@ -63,42 +156,96 @@ func (t *Tracker) GetStats() (*Stats, error) {
 	// Success rate
 	var successCount int64
 	_ = t.db.QueryRow(`
-		SELECT COUNT(*) FROM request_logs WHERE status_code = 200
-	`).Scan(&successCount)
+		SELECT COUNT(*) FROM request_logs WHERE status_code = 200 AND `+cond, args...,
+	).Scan(&successCount)
 	if stats.TotalRequests > 0 {
 		stats.SuccessRate = float64(successCount) / float64(stats.TotalRequests) * 100
 	}
 
-	// Today's requests
-	today := time.Now().Truncate(24 * time.Hour)
+	// Latency percentiles
+	dists, err := t.distributions("''", "", r)
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := dists[""]; ok {
+		stats.Latency, stats.TTFT = d.percentiles()
+	}
+
+	// Errors by status code
+	stats.ErrorsByStatus, err = t.errorsByStatus(cond, args)
+	if err != nil {
+		return nil, err
+	}
+
+	// Today's requests, from midnight in the configured timezone
+	now := time.Now().In(t.cfg.Location())
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 	_ = t.db.QueryRow(`
 		SELECT COUNT(*) FROM request_logs WHERE created_at >= ?
-	`, today).Scan(&stats.RequestsToday)
+	`, dbTime(today)).Scan(&stats.RequestsToday)
 
 	// This week's requests
-	weekAgo := time.Now().AddDate(0, 0, -7)
+	weekAgo := now.AddDate(0, 0, -7)
 	_ = t.db.QueryRow(`
 		SELECT COUNT(*) FROM request_logs WHERE created_at >= ?
-	`, weekAgo).Scan(&stats.RequestsThisWeek)
+	`, dbTime(weekAgo)).Scan(&stats.RequestsThisWeek)
 
 	// This month's requests
-	monthAgo := time.Now().AddDate(0, -1, 0)
+	monthAgo := now.AddDate(0, -1, 0)
 	_ = t.db.QueryRow(`
 		SELECT COUNT(*) FROM request_logs WHERE created_at >= ?
-	`, monthAgo).Scan(&stats.RequestsThisMonth)
+	`, dbTime(monthAgo)).Scan(&stats.RequestsThisMonth)
 
 	return &stats, nil
 }
 
-// GetModelStats returns per-model statistics
-func (t *Tracker) GetModelStats() ([]ModelStats, error) {
+// errorsByStatus counts failed requests per status code
+func (t *Tracker) errorsByStatus(cond string, args []interface{}) (map[string]int64, error) {
+	rows, err := t.db.Query(`
+		SELECT status_code, COUNT(*) FROM request_logs
+		WHERE status_code != 200 AND `+cond+`
+		GROUP BY status_code
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	errors := make(map[string]int64)
+	for rows.Next() {
+		var code int
+		var count int64
+		if err := rows.Scan(&code, &count); err != nil {
+			return nil, err
+		}
+		errors[strconv.Itoa(code)] = count
+	}
+	return errors, rows.Err()
+}
+
+// GetModelStats returns per-model statistics for the requests in the range,
+// the last DefaultStatsWindow without a start, grouped by routed model,
+// requested model or both (ByRouted, ByRequested, ByRoute)
+func (t *Tracker) GetModelStats(r StatsRange, by string) ([]ModelStats, error) {
+	r = r.since(DefaultStatsWindow)
+	routed, requested := "model", "''"
+	switch by {
+	case ByRequested:
+		routed, requested = "''", requestedModelExpr
+	case ByRoute:
+		requested = requestedModelExpr
+	}
+	cond, args := r.where("created_at")
+
 	rows, err := t.db.Query(`
-		SELECT model, COUNT(*), COALESCE(SUM(tokens_in), 0), 
-		       COALESCE(SUM(tokens_out), 0), COALESCE(AVG(latency_ms), 0)
+		SELECT `+routed+` AS routed, `+requested+` AS requested, COUNT(*),
+		       COALESCE(SUM(tokens_in), 0), COALESCE(SUM(tokens_out), 0), COALESCE(AVG(latency_ms), 0),
+		       CAST(SUM(CASE WHEN status_code = 200 THEN 1 ELSE 0 END) AS FLOAT) / COUNT(*) * 100
 		FROM request_logs
-		GROUP BY model
+		WHERE `+cond+`
+		GROUP BY routed, requested
 		ORDER BY COUNT(*) DESC
-	`)
+	`, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -107,26 +254,43 @@ func (t *Tracker) GetModelStats() ([]ModelStats, error) {
 	var stats []ModelStats
 	for rows.Next() {
 		var s ModelStats
-		if err := rows.Scan(&s.Model, &s.Requests, &s.TokensIn, &s.TokensOut, &s.AvgLatencyMs); err != nil {
+		if err := rows.Scan(&s.Model, &s.RequestedModel, &s.Requests, &s.TokensIn, &s.TokensOut, &s.AvgLatencyMs, &s.SuccessRate); err != nil {
 			return nil, err
 		}
 		stats = append(stats, s)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	dists, err := t.distributions(routed, requested, r)
+	if err != nil {
+		return nil, err
+	}
+	for i := range stats {
+		if d, ok := dists[stats[i].Model+"\x00"+stats[i].RequestedModel]; ok {
+			stats[i].Latency, stats[i].TTFT = d.percentiles()
+		}
+	}
 
 	return stats, nil
 }
 
-// GetAccountStats returns per-account statistics
-func (t *Tracker) GetAccountStats() ([]AccountStats, error) {
+// GetAccountStats returns per-account statistics for the requests in the
+// range, the last DefaultStatsWindow without a start
+func (t *Tracker) GetAccountStats(r StatsRange) ([]AccountStats, error) {
+	r = r.since(DefaultStatsWindow)
+	cond, args := r.where("r.created_at")
 	rows, err := t.db.Query(`
 		SELECT r.account_id, a.name, COUNT(*), COALESCE(SUM(r.tokens_in), 0),
 		       COALESCE(SUM(r.tokens_out), 0),
 		       CAST(SUM(CASE WHEN r.status_code = 200 THEN 1 ELSE 0 END) AS FLOAT) / COUNT(*) * 100
 		FROM request_logs r
 		JOIN accounts a ON r.account_id = a.id
+		WHERE `+cond+`
 		GROUP BY r.account_id
 		ORDER BY COUNT(*) DESC
-	`)
+	`, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -140,45 +304,214 @@ func (t *Tracker) GetAccountStats() ([]AccountStats, error) {
 		}
 		stats = append(stats, s)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	dists, err := t.distributions("CAST(account_id AS TEXT)", "", r)
+	if err != nil {
+		return nil, err
+	}
+	for i := range stats {
+		if d, ok := dists[strconv.FormatInt(stats[i].AccountID, 10)]; ok {
+			stats[i].Latency, stats[i].TTFT = d.percentiles()
+		}
+	}
 
 	return stats, nil
 }
 
-// GetHourlyStats returns hourly request counts for the last 24 hours
-func (t *Tracker) GetHourlyStats() ([]map[string]interface{}, error) {
+// distribution holds the latencies of successful requests in one group
+type distribution struct {
+	latency []int64
+	ttft    []int64
+}
+
+func (d *distribution) percentiles() (latency, ttft Percentiles) {
+	return percentiles(d.latency), percentiles(d.ttft)
+}
+
+// distributions loads the latencies of successful requests in the range,
+// keyed by the group expressions joined with a NUL; an empty second
+// expression keys by the first alone. Each group keeps its most recent
+// maxPercentileSamples requests. Requests logged without a time to first
+// token are left out of the TTFT distribution.
+func (t *Tracker) distributions(group, subgroup string, r StatsRange) (map[string]*distribution, error) {
+	cond, args := r.where("created_at")
+	key := group
+	if subgroup != "" {
+		key = group + " || char(0) || " + subgroup
+	}
+
 	rows, err := t.db.Query(`
-		SELECT strftime('%Y-%m-%d %H:00', created_at) as hour, COUNT(*)
-		FROM request_logs
-		WHERE created_at >= datetime('now', '-24 hours')
-		GROUP BY hour
-		ORDER BY hour
-	`)
+		SELECT k, latency_ms, ttft FROM (
+			SELECT `+key+` AS k, latency_ms, COALESCE(ttft_ms, 0) AS ttft,
+			       ROW_NUMBER() OVER (PARTITION BY `+key+` ORDER BY id DESC) AS n
+			FROM request_logs
+			WHERE status_code = 200 AND `+cond+`
+		) WHERE n <= ?`, append(args, maxPercentileSamples)...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var stats []map[string]interface{}
+	dists := make(map[string]*distribution)
 	for rows.Next() {
-		var hour string
-		var count int64
-		if err := rows.Scan(&hour, &count); err != nil {
+		var k string
+		var latency, ttft int64
+		if err := rows.Scan(&k, &latency, &ttft); err != nil {
 			return nil, err
 		}
+		d, ok := dists[k]
+		if !ok {
+			d = &distribution{}
+			dists[k] = d
+		}
+		d.latency = append(d.latency, latency)
+		if ttft > 0 {
+			d.ttft = append(d.ttft, ttft)
+		}
+	}
+	return dists, rows.Err()
+}
+
+// percentiles uses the nearest-rank method; values are sorted in place
+func percentiles(values []int64) Percentiles {
+	if len(values) == 0 {
+		return Percentiles{}
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	rank := func(p float64) int64 {
+		i := int(math.Ceil(p*float64(len(values)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return values[i]
+	}
+	return Percentiles{P50: rank(0.50), P95: rank(0.95), P99: rank(0.99)}
+}
+
+// timeSeriesBucket is the request, error and token count of one bucket
+type timeSeriesBucket struct {
+	start                    time.Time
+	requests, errors, tokens int64
+}
+
+// GetTimeSeries returns request, error and token counts per minute, hour or
+// day in the configured timezone, for buckets with requests. Without a
+// start it covers the last 24 hours.
+func (t *Tracker) GetTimeSeries(r StatsRange, granularity string) ([]map[string]interface{}, error) {
+	r = r.since(24 * time.Hour)
+	loc := t.cfg.Location()
+
+	// SQLite knows nothing of timezones, so the range is split where the
+	// UTC offset changes and each part is bucketed with its own offset.
+	// Buckets cut in two by a change are merged again below.
+	var buckets []*timeSeriesBucket
+	for start := r.From; ; {
+		part := StatsRange{From: start, To: r.To}
+		_, end := start.In(loc).ZoneBounds()
+		last := end.IsZero() || end.After(time.Now()) || (!r.To.IsZero() && !end.Before(r.To))
+		if !last {
+			part.To = end
+		}
+
+		parts, err := t.timeSeriesPart(part, granularity, loc)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range parts {
+			if n := len(buckets); n > 0 && buckets[n-1].start.Equal(b.start) {
+				buckets[n-1].requests += b.requests
+				buckets[n-1].errors += b.errors
+				buckets[n-1].tokens += b.tokens
+				continue
+			}
+			buckets = append(buckets, b)
+		}
+
+		if last {
+			break
+		}
+		start = end
+	}
+
+	stats := make([]map[string]interface{}, 0, len(buckets))
+	for _, b := range buckets {
 		stats = append(stats, map[string]interface{}{
-			"hour":     hour,
-			"requests": count,
+			"hour":     b.start.Format("2006-01-02 15:04"), // bucket start, kept under its original name
+			"time":     b.start.Format(time.RFC3339),
+			"requests": b.requests,
+			"errors":   b.errors,
+			"tokens":   b.tokens,
 		})
 	}
 
 	return stats, nil
 }
 
+// timeSeriesPart buckets the requests in a range throughout which loc has
+// the same UTC offset
+func (t *Tracker) timeSeriesPart(r StatsRange, granularity string, loc *time.Location) ([]*timeSeriesBucket, error) {
+	_, offset := r.From.In(loc).Zone()
+	zone := time.FixedZone("", offset)
+	size := int64(time.Hour / time.Second)
+	switch granularity {
+	case GranularityMinute:
+		size = int64(time.Minute / time.Second)
+	case GranularityDay:
+		size = int64(24 * time.Hour / time.Second)
+	}
+
+	cond, args := r.where("created_at")
+	rows, err := t.db.Query(`
+		SELECT (CAST(strftime('%s', created_at) AS INTEGER) + ?) / ? * ? - ? AS bucket,
+		       COUNT(*), SUM(CASE WHEN status_code != 200 THEN 1 ELSE 0 END),
+		       COALESCE(SUM(tokens_in + tokens_out), 0)
+		FROM request_logs
+		WHERE `+cond+`
+		GROUP BY bucket
+		ORDER BY bucket
+	`, append([]interface{}{offset, size, size, offset}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []*timeSeriesBucket
+	for rows.Next() {
+		var unix int64
+		b := &timeSeriesBucket{}
+		if err := rows.Scan(&unix, &b.requests, &b.errors, &b.tokens); err != nil {
+			return nil, err
+		}
+		// Rebuild the start from its wall clock: a bucket may begin before
+		// the part, where the offset was different
+		wall := time.Unix(unix, 0).In(zone)
+		b.start = truncate(time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), 0, 0, loc), granularity)
+		buckets = append(buckets, b)
+	}
+	return buckets, rows.Err()
+}
+
+// truncate returns the start of the minute, hour or day containing t, in
+// t's location
+func truncate(t time.Time, granularity string) time.Time {
+	switch granularity {
+	case GranularityMinute:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, t.Location())
+	case GranularityDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	}
+}
+
 // GetRecentRequests returns recent request logs
 func (t *Tracker) GetRecentRequests(limit int) ([]map[string]interface{}, error) {
 	rows, err := t.db.Query(`
 		SELECT r.id, r.account_id, a.name, r.model, r.tokens_in, r.tokens_out, 
-		       r.latency_ms, r.status_code, r.created_at
+		       r.latency_ms, r.status_code, r.error, r.created_at
 		FROM request_logs r
 		JOIN accounts a ON r.account_id = a.id
 		ORDER BY r.created_at DESC
@@ -194,9 +527,10 @@ func (t *Tracker) GetRecentRequests(limit int) ([]map[string]interface{}, error)
 		var (
 			id, accountID, tokensIn, tokensOut, latencyMs, statusCode int64
 			accountName, model                                        string
+			errMsg                                                    sql.NullString
 			createdAt                                                 time.Time
 		)
-		if err := rows.Scan(&id, &accountID, &accountName, &model, &tokensIn, &tokensOut, &latencyMs, &statusCode, &createdAt); err != nil {
+		if err := rows.Scan(&id, &accountID, &accountName, &model, &tokensIn, &tokensOut, &latencyMs, &statusCode, &errMsg, &createdAt); err != nil {
 			return nil, err
 		}
 		logs = append(logs, map[string]interface{}{
@@ -208,6 +542,7 @@ func (t *Tracker) GetRecentRequests(limit int) ([]map[string]interface{}, error)
 			"tokens_out":   tokensOut,
 			"latency_ms":   latencyMs,
 			"status_code":  statusCode,
+			"error":        errMsg.String,
 			"created_at":   createdAt.Format(time.RFC3339),
 		})
 	}
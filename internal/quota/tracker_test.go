@@ -0,0 +1,243 @@
+package quota
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"antigravity-lite/config"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// logEntry is a request_logs row for seeding test databases
+type logEntry struct {
+	at        time.Time
+	model     string
+	status    int
+	latencyMs int64
+	ttftMs    int64
+	tokens    int64
+}
+
+func newTestTracker(t *testing.T, timezone string, logs []logEntry) *Tracker {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec(`
+		CREATE TABLE accounts (id INTEGER PRIMARY KEY, name TEXT);
+		CREATE TABLE request_logs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			account_id INTEGER,
+			model TEXT,
+			tokens_in INTEGER,
+			tokens_out INTEGER,
+			latency_ms INTEGER,
+			status_code INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			error TEXT,
+			requested_model TEXT DEFAULT '',
+			ttft_ms INTEGER DEFAULT 0
+		);
+		INSERT INTO accounts (id, name) VALUES (1, 'test');
+	`); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range logs {
+		if _, err := db.Exec(`
+			INSERT INTO request_logs (account_id, model, tokens_in, tokens_out, latency_ms, status_code, ttft_ms, created_at)
+			VALUES (1, ?, ?, 0, ?, ?, ?, ?)
+		`, l.model, l.tokens, l.latencyMs, l.status, l.ttftMs, dbTime(l.at)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{}
+	cfg.Server.Timezone = timezone
+	return NewTracker(db, cfg)
+}
+
+func TestGetTimeSeries(t *testing.T) {
+	utc := func(s string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		name        string
+		timezone    string
+		granularity string
+		logs        []time.Time
+		from, to    time.Time
+		want        map[string]int64 // bucket start -> requests
+	}{
+		{
+			name:        "hours in a half-hour offset",
+			timezone:    "Asia/Kolkata", // UTC+5:30
+			granularity: GranularityHour,
+			logs:        []time.Time{utc("2026-03-01 10:20"), utc("2026-03-01 10:40"), utc("2026-03-01 11:10")},
+			from:        utc("2026-03-01 00:00"),
+			to:          utc("2026-03-02 00:00"),
+			want:        map[string]int64{"2026-03-01 15:00": 1, "2026-03-01 16:00": 2},
+		},
+		{
+			name:        "days split at local midnight",
+			timezone:    "Asia/Shanghai", // UTC+8
+			granularity: GranularityDay,
+			logs:        []time.Time{utc("2026-03-01 15:59"), utc("2026-03-01 16:00"), utc("2026-03-02 01:00")},
+			from:        utc("2026-03-01 00:00"),
+			to:          utc("2026-03-03 00:00"),
+			want:        map[string]int64{"2026-03-01 00:00": 1, "2026-03-02 00:00": 2},
+		},
+		{
+			name:        "day across a DST change",
+			timezone:    "America/New_York", // clocks go forward at 07:00 UTC on 2026-03-08
+			granularity: GranularityDay,
+			logs:        []time.Time{utc("2026-03-08 06:00"), utc("2026-03-08 08:00"), utc("2026-03-09 03:59"), utc("2026-03-09 04:00")},
+			from:        utc("2026-03-07 00:00"),
+			to:          utc("2026-03-10 00:00"),
+			want:        map[string]int64{"2026-03-08 00:00": 3, "2026-03-09 00:00": 1},
+		},
+		{
+			name:        "minutes",
+			timezone:    "UTC",
+			granularity: GranularityMinute,
+			logs:        []time.Time{utc("2026-03-01 10:20"), utc("2026-03-01 10:20").Add(59 * time.Second), utc("2026-03-01 10:21")},
+			from:        utc("2026-03-01 00:00"),
+			to:          utc("2026-03-02 00:00"),
+			want:        map[string]int64{"2026-03-01 10:20": 2, "2026-03-01 10:21": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs []logEntry
+			for _, at := range tt.logs {
+				logs = append(logs, logEntry{at: at, model: "m", status: 200, tokens: 10})
+			}
+			tracker := newTestTracker(t, tt.timezone, logs)
+
+			series, err := tracker.GetTimeSeries(StatsRange{From: tt.from, To: tt.to}, tt.granularity)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]int64)
+			for _, b := range series {
+				got[b["hour"].(string)] = b["requests"].(int64)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("buckets = %v, want %v", got, tt.want)
+			}
+			for start, n := range tt.want {
+				if got[start] != n {
+					t.Errorf("bucket %s = %d requests, want %d (all: %v)", start, got[start], n, got)
+				}
+			}
+		})
+	}
+}
+
+func TestPercentiles(t *testing.T) {
+	seq := func(n int) []int64 {
+		values := make([]int64, n)
+		for i := range values {
+			values[i] = int64(n - i) // descending, to check sorting
+		}
+		return values
+	}
+
+	tests := []struct {
+		name   string
+		values []int64
+		want   Percentiles
+	}{
+		{"empty", nil, Percentiles{}},
+		{"one", []int64{42}, Percentiles{P50: 42, P95: 42, P99: 42}},
+		{"two", []int64{200, 100}, Percentiles{P50: 100, P95: 200, P99: 200}},
+		{"hundred", seq(100), Percentiles{P50: 50, P95: 95, P99: 99}},
+		{"thousand", seq(1000), Percentiles{P50: 500, P95: 950, P99: 990}},
+	}
+	for _, tt := range tests {
+		if got := percentiles(tt.values); got != tt.want {
+			t.Errorf("%s: percentiles = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGetStats(t *testing.T) {
+	const timezone = "Asia/Shanghai" // UTC+8, no DST
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().In(loc)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if now.Sub(midnight) < 2*time.Minute {
+		t.Skip("too close to midnight in", timezone)
+	}
+
+	var logs []logEntry
+	for i := int64(1); i <= 100; i++ {
+		logs = append(logs, logEntry{at: now.Add(-time.Minute), model: "fast", status: 200, latencyMs: i * 10, ttftMs: i})
+	}
+	logs = append(logs,
+		// Non-streamed: no time to first token
+		logEntry{at: now.Add(-time.Minute), model: "fast", status: 200, latencyMs: 5000},
+		logEntry{at: now.Add(-time.Minute), model: "slow", status: 429, latencyMs: 1},
+		logEntry{at: now.Add(-time.Minute), model: "slow", status: 429, latencyMs: 1},
+		logEntry{at: now.Add(-time.Minute), model: "slow", status: 500, latencyMs: 1},
+		// Yesterday in the configured timezone
+		logEntry{at: midnight.Add(-time.Second), model: "slow", status: 200, latencyMs: 7},
+		// Outside the default window
+		logEntry{at: now.Add(-DefaultStatsWindow - time.Hour), model: "old", status: 200, latencyMs: 1},
+	)
+	tracker := newTestTracker(t, timezone, logs)
+
+	stats, err := tracker.GetStats(StatsRange{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalRequests != 105 {
+		t.Errorf("TotalRequests = %d, want 105", stats.TotalRequests)
+	}
+	if stats.RequestsToday != 104 {
+		t.Errorf("RequestsToday = %d, want 104", stats.RequestsToday)
+	}
+	if want := (Percentiles{P50: 500, P95: 960, P99: 1000}); stats.Latency != want {
+		t.Errorf("Latency = %+v, want %+v", stats.Latency, want)
+	}
+	if want := (Percentiles{P50: 50, P95: 95, P99: 99}); stats.TTFT != want {
+		t.Errorf("TTFT = %+v, want %+v", stats.TTFT, want)
+	}
+	if stats.ErrorsByStatus["429"] != 2 || stats.ErrorsByStatus["500"] != 1 || len(stats.ErrorsByStatus) != 2 {
+		t.Errorf("ErrorsByStatus = %v, want 429: 2, 500: 1", stats.ErrorsByStatus)
+	}
+
+	models, err := tracker.GetModelStats(StatsRange{}, ByRouted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range models {
+		switch m.Model {
+		case "fast":
+			if m.Requests != 101 || m.TTFT.P99 != 99 {
+				t.Errorf("fast: %+v", m)
+			}
+		case "slow":
+			if m.Requests != 4 || m.Latency.P50 != 7 {
+				t.Errorf("slow: %+v", m)
+			}
+		default:
+			t.Errorf("unexpected model %q outside the default window", m.Model)
+		}
+	}
+}
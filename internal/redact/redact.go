@@ -0,0 +1,80 @@
+package redact
+
+import (
+	"io"
+	"regexp"
+	"sync"
+)
+
+// rule replaces every match of pattern with replacement
+type rule struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// Order matters: specific token formats are scrubbed before the generic ones
+var rules = []rule{
+	// Authorization headers
+	{regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`), "${1}[REDACTED]"},
+	// Google OAuth access and refresh tokens
+	{regexp.MustCompile(`ya29\.[A-Za-z0-9._-]+`), "[ACCESS_TOKEN]"},
+	{regexp.MustCompile(`1//[A-Za-z0-9._-]+`), "[REFRESH_TOKEN]"},
+	// JWTs (id_token etc.)
+	{regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`), "[JWT]"},
+	// API keys and client secrets
+	{regexp.MustCompile(`AIza[A-Za-z0-9_-]{35}`), "[API_KEY]"},
+	{regexp.MustCompile(`GOCSPX-[A-Za-z0-9_-]+`), "[CLIENT_SECRET]"},
+	{regexp.MustCompile(`sk-[A-Za-z0-9_-]{8,}`), "[API_KEY]"},
+	// Token fields in JSON / form bodies
+	{regexp.MustCompile(`("(?:access_token|refresh_token|id_token|client_secret|api_key)"\s*:\s*")[^"]*"`), `${1}[REDACTED]"`},
+	{regexp.MustCompile(`((?:access_token|refresh_token|id_token|client_secret)=)[^&\s]+`), "${1}[REDACTED]"},
+	// Email addresses
+	{regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), "[EMAIL]"},
+	// GCP project IDs and numbers
+	{regexp.MustCompile(`(projects/)[A-Za-z0-9_-]+`), "${1}[PROJECT]"},
+	{regexp.MustCompile(`("(?:project|projectId|project_id|cloudaicompanionProject|consumer)"\s*:\s*")[^"]*"`), `${1}[PROJECT]"`},
+	{regexp.MustCompile(`(?i)(project(?:[ _-]?id)?(?:\s*[:=]\s*|\s+['"]))[a-z][a-z0-9-]{4,28}[a-z0-9]\b`), "${1}[PROJECT]"},
+	// Free-text mentions like "on resource project my-proj-123" or "project 123456789"
+	{regexp.MustCompile(`(?i)(\bproject\s+)(?:[a-z][a-z0-9]*-[a-z0-9-]*[a-z0-9]|\d{6,})\b`), "${1}[PROJECT]"},
+}
+
+// String scrubs tokens, emails and project IDs from s.
+// Use it on anything that leaves the process towards API consumers or logs.
+func String(s string) string {
+	for _, r := range rules {
+		s = r.pattern.ReplaceAllString(s, r.replacement)
+	}
+	return s
+}
+
+// Error returns the redacted message of err, or "" for nil
+func Error(err error) string {
+	if err == nil {
+		return ""
+	}
+	return String(err.Error())
+}
+
+// Writer redacts everything written through it. Wrap the log output with it
+// so every log line is scrubbed.
+type Writer struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewWriter creates a redacting writer
+func NewWriter(out io.Writer) *Writer {
+	return &Writer{out: out}
+}
+
+// Write implements io.Writer. The log package writes one line per call,
+// so redacting per call never splits a secret.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err := w.out.Write([]byte(String(string(p)))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
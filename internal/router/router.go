@@ -12,10 +12,12 @@ import (
 type Router struct {
 	routes    map[string]string
 	patterns  []patternRoute
+	pools     map[string][]string // route pattern -> allowed account pools
 	mu        sync.RWMutex
 }
 
 type patternRoute struct {
+	source  string
 	pattern *regexp.Regexp
 	target  string
 }
@@ -24,11 +26,15 @@ type patternRoute struct {
 func NewRouter(cfg *config.Config) *Router {
 	r := &Router{
 		routes: make(map[string]string),
+		pools:  make(map[string][]string),
 	}
 
 	// Load routes from config
 	for _, route := range cfg.Routes {
 		r.AddRoute(route.Pattern, route.Target)
+		if len(route.Pools) > 0 {
+			r.pools[route.Pattern] = route.Pools
+		}
 	}
 
 	return r
@@ -45,6 +51,7 @@ func (r *Router) AddRoute(pattern, target string) {
 		regexPattern := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$"
 		if re, err := regexp.Compile(regexPattern); err == nil {
 			r.patterns = append(r.patterns, patternRoute{
+				source:  pattern,
 				pattern: re,
 				target:  target,
 			})
@@ -61,6 +68,7 @@ func (r *Router) RemoveRoute(pattern string) {
 	defer r.mu.Unlock()
 
 	delete(r.routes, pattern)
+	delete(r.pools, pattern)
 
 	// Remove from patterns
 	for i, p := range r.patterns {
@@ -76,23 +84,85 @@ func (r *Router) Route(model string) string {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	// Check exact match first
-	if target, ok := r.routes[model]; ok {
+	if _, target, ok := r.match(model); ok {
 		return target
 	}
 
-	// Check pattern matches
+	// No match, return original
+	return model
+}
+
+// Pools returns the account pools the route matching model is restricted
+// to, or nil if any account may serve it
+func (r *Router) Pools(model string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if pattern, _, ok := r.match(model); ok {
+		return r.pools[pattern]
+	}
+	return nil
+}
+
+// GetPools returns the pool restrictions of all routes
+func (r *Router) GetPools() map[string][]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	pools := make(map[string][]string, len(r.pools))
+	for k, v := range r.pools {
+		pools[k] = v
+	}
+	return pools
+}
+
+// SetPools sets the pool restrictions of the given route patterns; an empty
+// list lifts the restriction. Patterns without a route are ignored.
+func (r *Router) SetPools(pools map[string][]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for pattern, p := range pools {
+		if !r.hasRoute(pattern) {
+			continue
+		}
+		if len(p) == 0 {
+			delete(r.pools, pattern)
+		} else {
+			r.pools[pattern] = p
+		}
+	}
+}
+
+// hasRoute reports whether a route with this exact pattern exists.
+// Callers must hold r.mu.
+func (r *Router) hasRoute(pattern string) bool {
+	if _, ok := r.routes[pattern]; ok {
+		return true
+	}
 	for _, p := range r.patterns {
-		if p.pattern.MatchString(model) {
-			return p.target
+		if p.source == pattern {
+			return true
 		}
 	}
+	return false
+}
 
-	// No match, return original
-	return model
+// match finds the route for a model, exact matches first.
+// Callers must hold r.mu.
+func (r *Router) match(model string) (pattern, target string, ok bool) {
+	if target, ok := r.routes[model]; ok {
+		return model, target, true
+	}
+	for _, p := range r.patterns {
+		if p.pattern.MatchString(model) {
+			return p.source, p.target, true
+		}
+	}
+	return "", "", false
 }
 
-// GetRoutes returns all routes
+// GetRoutes returns all routes, exact and wildcard
 func (r *Router) GetRoutes() map[string]string {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -101,11 +171,15 @@ func (r *Router) GetRoutes() map[string]string {
 	for k, v := range r.routes {
 		routes[k] = v
 	}
+	for _, p := range r.patterns {
+		routes[p.source] = p.target
+	}
 
 	return routes
 }
 
-// SetRoutes replaces all routes
+// SetRoutes replaces all routes. Pool restrictions are kept for patterns
+// that remain.
 func (r *Router) SetRoutes(routes map[string]string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -118,6 +192,7 @@ func (r *Router) SetRoutes(routes map[string]string) {
 			regexPattern := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$"
 			if re, err := regexp.Compile(regexPattern); err == nil {
 				r.patterns = append(r.patterns, patternRoute{
+					source:  pattern,
 					pattern: re,
 					target:  target,
 				})
@@ -126,6 +201,12 @@ func (r *Router) SetRoutes(routes map[string]string) {
 			r.routes[pattern] = target
 		}
 	}
+
+	for pattern := range r.pools {
+		if _, ok := routes[pattern]; !ok {
+			delete(r.pools, pattern)
+		}
+	}
 }
 
 // IsBackgroundRequest checks if the request is a background task
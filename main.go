@@ -1,19 +1,30 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"errors"
 	"fmt"
 	"io/fs"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
 
 	"antigravity-lite/config"
 	"antigravity-lite/internal/account"
+	"antigravity-lite/internal/alerts"
 	"antigravity-lite/internal/api"
+	"antigravity-lite/internal/audit"
+	"antigravity-lite/internal/certs"
+	"antigravity-lite/internal/jobs"
+	"antigravity-lite/internal/middleware"
 	"antigravity-lite/internal/proxy"
 	"antigravity-lite/internal/quota"
+	"antigravity-lite/internal/redact"
 	"antigravity-lite/internal/router"
 
 	"github.com/gin-gonic/gin"
@@ -23,6 +34,9 @@ import (
 var webFS embed.FS
 
 func main() {
+	// Scrub tokens, emails and project IDs from every log line
+	log.SetOutput(redact.NewWriter(os.Stderr))
+
 	// Determine config path
 	configPath := os.Getenv("CONFIG_PATH")
 	if configPath == "" {
@@ -51,11 +65,23 @@ func main() {
 	defer storage.Close()
 
 	// Initialize components
-	accountMgr := account.NewManager(storage)
+	accountMgr := account.NewManager(storage, cfg)
 	modelRouter := router.NewRouter(cfg)
-	quotaTracker := quota.NewTracker(storage.DB())
+	quotaTracker := quota.NewTracker(storage.DB(), cfg)
+	auditLog := audit.NewLogger(storage.DB(),
+		func() string { return cfg.Server.APIKey },
+		func(key string) string {
+			if ck := middleware.MatchClientKey(cfg.ClientKeys, key); ck != nil {
+				return ck.Name
+			}
+			return ""
+		})
 	proxyHandler := proxy.NewHandler(accountMgr, modelRouter, cfg)
-	apiHandler := api.NewHandler(accountMgr, modelRouter, quotaTracker, cfg, configPath)
+	jobScheduler := jobs.NewScheduler(storage.DB(), accountMgr, cfg)
+	jobScheduler.Start()
+	alerter := alerts.NewAlerter(accountMgr, cfg)
+	alerter.Start()
+	apiHandler := api.NewHandler(accountMgr, modelRouter, quotaTracker, auditLog, jobScheduler, alerter, cfg, configPath)
 
 	// Setup Gin
 	if cfg.Server.LogLevel != "debug" {
@@ -64,17 +90,14 @@ func main() {
 	r := gin.New()
 	r.Use(gin.Recovery())
 
-	// Add CORS middleware
-	r.Use(func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-		c.Next()
-	})
+	// Add CORS middleware (separate policies for proxy and management API)
+	r.Use(middleware.CORS(&cfg.CORS.Proxy, "/v1", "/v1beta"))
+	r.Use(middleware.CORS(&cfg.CORS.API, "/api"))
+
+	// Client key authentication, then ingress rate limiting for proxy endpoints
+	r.Use(middleware.ClientKeys(cfg, "/v1", "/v1beta"))
+	rateLimiter := middleware.NewRateLimiter(&cfg.RateLimit)
+	r.Use(rateLimiter.Middleware("/v1", "/v1beta"))
 
 	// API Proxy endpoints (OpenAI compatible)
 	r.POST("/v1/chat/completions", proxyHandler.HandleChatCompletions)
@@ -92,6 +115,7 @@ func main() {
 
 	// Management API
 	apiGroup := r.Group("/api")
+	apiGroup.Use(auditLog.Middleware())
 	{
 		// Dashboard
 		apiGroup.GET("/dashboard", apiHandler.Dashboard)
@@ -102,12 +126,28 @@ func main() {
 		apiGroup.GET("/accounts/:id", apiHandler.GetAccount)
 		apiGroup.PUT("/accounts/:id", apiHandler.UpdateAccount)
 		apiGroup.DELETE("/accounts/:id", apiHandler.DeleteAccount)
+		apiGroup.PUT("/accounts/:id/tags", apiHandler.UpdateAccountTags)
+		apiGroup.PUT("/accounts/:id/weight", apiHandler.UpdateAccountWeight)
+		apiGroup.PUT("/accounts/:id/pacing", apiHandler.UpdateAccountPacing)
+		apiGroup.POST("/accounts/:id/disable", apiHandler.DisableAccount)
+		apiGroup.POST("/accounts/:id/enable", apiHandler.EnableAccount)
 		apiGroup.POST("/accounts/:id/check", apiHandler.CheckAccount)
 		apiGroup.POST("/accounts/check-all", apiHandler.CheckAllAccounts)
 		apiGroup.POST("/accounts/import", apiHandler.ImportAccounts)
 		apiGroup.GET("/accounts/export", apiHandler.ExportAccounts)
 		apiGroup.POST("/accounts/:id/quota", apiHandler.RefreshQuota)
 		apiGroup.POST("/accounts/refresh-quotas", apiHandler.RefreshAllQuotas)
+		apiGroup.GET("/accounts/:id/quota/history", apiHandler.GetQuotaHistory)
+		apiGroup.GET("/quota/summary", apiHandler.GetQuotaSummary)
+		apiGroup.GET("/quota/forecast", apiHandler.GetQuotaForecast)
+
+		// Pools
+		apiGroup.GET("/pools", apiHandler.ListPools)
+
+		// Sessions
+		apiGroup.GET("/sessions", apiHandler.ListSessions)
+		apiGroup.DELETE("/sessions", apiHandler.ClearSessions)
+		apiGroup.DELETE("/sessions/:id", apiHandler.ClearSession)
 
 		// Routes
 		apiGroup.GET("/routes", apiHandler.GetRoutes)
@@ -124,6 +164,18 @@ func main() {
 		apiGroup.GET("/config", apiHandler.GetConfig)
 		apiGroup.PUT("/config", apiHandler.UpdateConfig)
 
+		// Audit
+		apiGroup.GET("/audit", apiHandler.GetAuditLog)
+
+		// Background jobs
+		apiGroup.GET("/jobs", apiHandler.ListJobs)
+		apiGroup.GET("/jobs/:name", apiHandler.GetJob)
+		apiGroup.POST("/jobs/:name/run", apiHandler.RunJob)
+
+		// Alerts
+		apiGroup.GET("/alerts", apiHandler.ListAlerts)
+		apiGroup.POST("/alerts/test", apiHandler.TestAlerts)
+
 		// OAuth
 		apiGroup.GET("/oauth/start", apiHandler.StartOAuth)
 		apiGroup.GET("/oauth/callback", apiHandler.OAuthCallback)
@@ -154,12 +206,50 @@ func main() {
 
 	// Start server
 	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
-	log.Printf("🚀 Antigravity Lite starting on http://%s", addr)
-	log.Printf("📊 Dashboard: http://%s/", addr)
-	log.Printf("🔌 OpenAI API: http://%s/v1/chat/completions", addr)
-	log.Printf("🔌 Anthropic API: http://%s/v1/messages", addr)
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: r,
+	}
+
+	scheme := "http"
+	if cfg.Server.TLSCert != "" && cfg.Server.TLSKey != "" {
+		reloader, err := certs.NewReloader(cfg.Server.TLSCert, cfg.Server.TLSKey, cfg.Server.TLSClientCA, cfg.Server.TLSClientAuth)
+		if err != nil {
+			log.Fatalf("Failed to load TLS configuration: %v", err)
+		}
+		go reloader.Watch(30 * time.Second)
+		srv.TLSConfig = reloader.TLSConfig()
+		scheme = "https"
+	} else if cfg.Server.LANAccess {
+		log.Printf("⚠️  LAN access is enabled without TLS, API keys and prompts are sent in clear text")
+	}
 
-	if err := r.Run(addr); err != nil {
+	log.Printf("🚀 Antigravity Lite starting on %s://%s", scheme, addr)
+	log.Printf("📊 Dashboard: %s://%s/", scheme, addr)
+	log.Printf("🔌 OpenAI API: %s://%s/v1/chat/completions", scheme, addr)
+	log.Printf("🔌 Anthropic API: %s://%s/v1/messages", scheme, addr)
+
+	// Shut down gracefully so pending rate limits and sessions are saved
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		log.Printf("Shutting down...")
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	}()
+
+	if scheme == "https" {
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("Failed to start server: %v", err)
 	}
+
+	jobScheduler.Stop()
+	alerter.Stop()
+	accountMgr.Close()
 }
@@ -217,6 +217,9 @@ function renderAccounts() {
             <td>${formatDateTime(acc.last_used_at)}</td>
             <td>
                 <button class="btn btn-sm btn-secondary" onclick="checkAccount(${acc.id})" title="检测">🔍</button>
+                <button class="btn btn-sm btn-secondary" onclick="editAccountTags(${acc.id})" title="账号池标签">🏷️</button>
+                <button class="btn btn-sm btn-secondary" onclick="editAccountWeight(${acc.id})" title="调度权重">⚖️</button>
+                <button class="btn btn-sm btn-secondary" onclick="editAccountPacing(${acc.id})" title="节奏限制">⏱️</button>
                 <button class="btn btn-sm btn-secondary" onclick="toggleAccountStatus(${acc.id})" title="启用/禁用">⊘</button>
                 <button class="btn btn-sm btn-danger" onclick="deleteAccount(${acc.id})" title="删除">🗑️</button>
             </td>
@@ -242,11 +245,54 @@ function getAccountBadges(acc, index) {
 
     if (acc.status === 'banned' || acc.status === 'expired') {
         badges += '<span class="badge badge-disabled">已禁用</span>';
+    } else if (acc.status === 'disabled') {
+        badges += '<span class="badge badge-paused">已暂停</span>';
+    }
+
+    if (acc.weight && acc.weight !== 100) {
+        badges += `<span class="badge badge-tag" title="调度权重">权重 ${acc.weight}</span>`;
+    }
+
+    (acc.tags || []).forEach(tag => {
+        badges += `<span class="badge badge-tag">${escapeHtml(tag)}</span>`;
+    });
+
+    badges += getCircuitBadge(acc.circuit);
+
+    if (acc.pacing_status) {
+        const ps = acc.pacing_status;
+        const cap = acc.pacing?.daily_cap > 0 ? `/${acc.pacing.daily_cap}` : '';
+        const next = ps.next_allowed_at && !ps.next_allowed_at.startsWith('0001')
+            ? `，下次可用 ${new Date(ps.next_allowed_at).toLocaleTimeString()}` : '';
+        const title = `24 小时 ${ps.requests_24h} 次，1 小时 ${ps.requests_1h} 次${ps.in_window ? '' : '，不在可用时段'}${next}`;
+        badges += `<span class="badge ${next ? 'badge-paused' : 'badge-tag'}" title="${title}">节奏 ${ps.requests_24h}${cap}</span>`;
+    }
+
+    (acc.token_usage || []).forEach(u => {
+        if (!u.exhausted_until || u.exhausted_until.startsWith('0001')) return;
+        const title = `今日 ${u.daily}${u.daily_budget ? '/' + u.daily_budget : ''}，5 小时 ${u.rolling_5h}${u.rolling_5h_budget ? '/' + u.rolling_5h_budget : ''}，${new Date(u.exhausted_until).toLocaleTimeString()} 恢复`;
+        badges += `<span class="badge badge-paused" title="${title}">预算用尽 ${escapeHtml(u.model)}</span>`;
+    });
+
+    if (acc.in_flight > 0) {
+        const limit = acc.max_concurrency > 0 ? `/${acc.max_concurrency}` : '';
+        const full = acc.max_concurrency > 0 && acc.in_flight >= acc.max_concurrency;
+        badges += `<span class="badge ${full ? 'badge-circuit' : 'badge-tag'}" title="进行中的请求">并发 ${acc.in_flight}${limit}</span>`;
     }
 
     return badges;
 }
 
+function getCircuitBadge(circuit) {
+    if (!circuit || circuit.state === 'closed') return '';
+
+    const title = escapeHtml(`连续失败 ${circuit.failures} 次${circuit.last_error ? ': ' + circuit.last_error : ''}`);
+    if (circuit.state === 'half_open') {
+        return `<span class="badge badge-circuit" title="${title}">熔断探测中</span>`;
+    }
+    return `<span class="badge badge-circuit" title="${title}">熔断至 ${formatDateTime(circuit.open_until)}</span>`;
+}
+
 function getQuotaTags(acc) {
     // Display quota if available from account data
     if (acc.quota_limit && acc.quota_limit > 0) {
@@ -474,7 +520,111 @@ async function deleteAccount(id) {
 }
 
 async function toggleAccountStatus(id) {
-    showToast('切换账号状态');
+    const acc = allAccounts.find(a => a.id === id);
+    const enable = acc?.status === 'disabled';
+
+    try {
+        const res = await fetch(`${API_BASE}/api/accounts/${id}/${enable ? 'enable' : 'disable'}`, { method: 'POST' });
+        const data = await res.json();
+        if (data.error) {
+            showToast('切换失败: ' + data.error, 'error');
+            return;
+        }
+        showToast(enable ? '账号已启用' : '账号已暂停');
+        loadAccounts();
+    } catch (err) {
+        showToast('切换失败: ' + err.message, 'error');
+    }
+}
+
+async function editAccountWeight(id) {
+    const acc = allAccounts.find(a => a.id === id);
+    const input = prompt('调度权重（1-1000，默认 100，越低分配越少）', acc?.weight || 100);
+    if (input === null) return;
+
+    const weight = parseInt(input, 10);
+    if (!(weight >= 1 && weight <= 1000)) {
+        showToast('权重需为 1-1000 的整数', 'error');
+        return;
+    }
+
+    try {
+        const res = await fetch(`${API_BASE}/api/accounts/${id}/weight`, {
+            method: 'PUT',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ weight })
+        });
+        const data = await res.json();
+        if (data.error) {
+            showToast('保存失败: ' + data.error, 'error');
+            return;
+        }
+        showToast('权重已更新');
+        loadAccounts();
+    } catch (err) {
+        showToast('保存失败: ' + err.message, 'error');
+    }
+}
+
+async function editAccountPacing(id) {
+    const acc = allAccounts.find(a => a.id === id);
+    const current = acc?.pacing || { daily_cap: 0, hourly_cap: 0, min_gap: 0, jitter: 0, window: '', timezone: '' };
+    const input = prompt(
+        '节奏限制（JSON，0 或空表示不限制）\n' +
+        'daily_cap/hourly_cap: 24 小时/1 小时请求上限；min_gap/jitter: 请求间隔与随机抖动（秒）；\n' +
+        'window: 可用时段如 08:00-23:00；timezone: 如 Asia/Shanghai',
+        JSON.stringify(current)
+    );
+    if (input === null) return;
+
+    let pacing;
+    try {
+        pacing = input.trim() ? JSON.parse(input) : {};
+    } catch (err) {
+        showToast('JSON 格式错误', 'error');
+        return;
+    }
+
+    try {
+        const res = await fetch(`${API_BASE}/api/accounts/${id}/pacing`, {
+            method: 'PUT',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(pacing)
+        });
+        const data = await res.json();
+        if (data.error) {
+            showToast('保存失败: ' + data.error, 'error');
+            return;
+        }
+        showToast('节奏限制已更新');
+        loadAccounts();
+    } catch (err) {
+        showToast('保存失败: ' + err.message, 'error');
+    }
+}
+
+async function editAccountTags(id) {
+    const acc = allAccounts.find(a => a.id === id);
+    const input = prompt('账号池标签（逗号分隔，如 team,ultra）', (acc?.tags || []).join(','));
+    if (input === null) return;
+
+    const tags = input.split(',').map(t => t.trim()).filter(Boolean);
+    try {
+        const res = await fetch(`${API_BASE}/api/accounts/${id}/tags`, {
+            method: 'PUT',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ tags })
+        });
+        const data = await res.json();
+        if (data.error) {
+            showToast('保存失败: ' + data.error, 'error');
+            return;
+        }
+        showToast('标签已更新');
+        loadAccounts();
+    } catch (err) {
+        showToast('保存失败: ' + err.message, 'error');
+    }
 }
 
 async function checkAccount(id) {
@@ -739,8 +889,15 @@ function generateRandomString(length) {
     return result;
 }
 
-function clearSessionBinding() {
-    showToast('会话绑定已清除');
+async function clearSessionBinding() {
+    try {
+        const res = await fetch(`${API_BASE}/api/sessions`, { method: 'DELETE' });
+        const data = await res.json();
+        if (!res.ok) throw new Error(data.error || res.statusText);
+        showToast(`会话绑定已清除（${data.removed} 个）`);
+    } catch (e) {
+        showToast('清除会话失败: ' + e.message, 'error');
+    }
 }
 
 function openMonitor() {
@@ -765,6 +922,7 @@ function getStatusText(status) {
         'expired': '⚠ 过期',
         'banned': '✗ 封禁',
         'checking': '⟳ 检测中',
+        'disabled': '⏸ 已暂停',
         'unknown': '? 未知'
     };
     return map[status] || status;
@@ -896,10 +1054,8 @@ async function loadChartData() {
         const hourlyData = await hourlyRes.json() || [];
 
         if (hourlyChart && hourlyData.length > 0) {
-            hourlyChart.data.labels = hourlyData.map(d => {
-                const date = new Date(d.hour);
-                return date.getHours() + ':00';
-            });
+            // Bucket labels are already in the server's configured timezone
+            hourlyChart.data.labels = hourlyData.map(d => d.hour.slice(11));
             hourlyChart.data.datasets[0].data = hourlyData.map(d => d.requests);
             hourlyChart.update();
         }